@@ -0,0 +1,149 @@
+package glib
+
+import (
+	"context"
+	"sync"
+
+	gtrace "github.com/carltd/glib/trace"
+)
+
+// App holds an isolated configuration and the resources managed by glib,
+// so several apps can run in one process, eg: in parallel tests.
+// the tracer is shared by all apps in the process, the last initialized one wins and closes it.
+type App struct {
+	ctx     context.Context
+	stop    context.CancelFunc
+	conf    *configCenter
+	enabled *featureEnabledOptions
+
+	dbs        sync.Map
+	caches     sync.Map
+	rediss     sync.Map
+	mgos       sync.Map
+	publishers sync.Map
+	consumers  sync.Map
+
+	// last *HealthStatus of the resources by kind[alias]
+	health sync.Map
+	// *latencyRecorder of the db operations by dbMetricKey
+	dbMetrics sync.Map
+
+	// deregisters the service, nil if it's not registered
+	registered *closing
+	// *DistLock held
+	locks sync.Map
+
+	shutdownOnce sync.Once
+}
+
+func newApp() *App {
+	a := &App{enabled: &featureEnabledOptions{}}
+	a.ctx, a.stop = context.WithCancel(context.Background())
+	return a
+}
+
+// New creates an App with enabled features, the config of all enabled features will be
+// validated before any connection is opened, the problems are returned in a ConfigError.
+func New(opts ...option) (*App, error) {
+	var (
+		a   = newApp()
+		err error
+	)
+
+	a.conf, err = newConfigCenter(opts...)
+	if err != nil {
+		return nil, a.release(err)
+	}
+
+	cfg, err := loadConfigs(a.conf, a.enabled)
+	if err != nil {
+		return nil, a.release(err)
+	}
+
+	// init database
+	if a.enabled.Db {
+		if err = a.runDBManger(cfg.db...); err != nil {
+			return nil, a.release(err)
+		}
+		if a.conf.Options().Migrate {
+			if err = a.migrate(); err != nil {
+				return nil, a.release(err)
+			}
+		}
+	}
+
+	// init cache
+	if a.enabled.Cache {
+		if err = a.runCacheManger(cfg.cache...); err != nil {
+			return nil, a.release(err)
+		}
+	}
+
+	// init mongodb
+	if a.enabled.Mgo {
+		if err = a.runMgoManager(cfg.mgo...); err != nil {
+			return nil, a.release(err)
+		}
+	}
+
+	// init broker
+	if a.enabled.Broker {
+		if err = a.runBrokerManager(cfg.broker...); err != nil {
+			return nil, a.release(err)
+		}
+	}
+
+	if a.enabled.Redis {
+		if err = a.runRedisManger(cfg.redis...); err != nil {
+			return nil, a.release(err)
+		}
+	}
+
+	// init tracer
+	if a.enabled.Tracer {
+		tCfg := *cfg.tracer
+		tCfg.SrvName = a.conf.Options().ServiceDomain
+		tCfg.HostPort = a.conf.Options().RunAt
+		if err = gtrace.InitTracer(tCfg); err != nil {
+			return nil, a.release(err)
+		}
+		a.ownTracer()
+	}
+
+	a.watchResources()
+	go a.conf.Watch(a.ctx)
+
+	if a.conf.Options().Register {
+		if err = a.register(); err != nil {
+			return nil, a.release(err)
+		}
+	}
+
+	return a, nil
+}
+
+// GetConfig - see the package function GetConfig
+func (a *App) GetConfig(keyPath string) ConfigObject {
+	return a.conf.Raw(keyPath)
+}
+
+// ConfigOrigin - see the package function ConfigOrigin
+func (a *App) ConfigOrigin(keyPath string) []string {
+	return a.conf.Origin(keyPath)
+}
+
+// WatchConfig - see the package function WatchConfig
+func (a *App) WatchConfig(keyPath string, fn ConfigWatcher) {
+	a.conf.AddWatcher(keyPath, fn)
+}
+
+// release the resources opened when New failed
+func (a *App) release(err error) error {
+	_ = a.Shutdown(context.Background())
+	return err
+}
+
+// Close releases the resources managed by the app, see Shutdown
+func (a *App) Close() error {
+	return a.Shutdown(context.Background())
+}
@@ -0,0 +1,45 @@
+package glib
+
+import (
+	"os"
+	"testing"
+
+	"github.com/carltd/glib/internal"
+)
+
+func TestNew_Isolated(t *testing.T) {
+	internal.RegisterCacheDriver("fake", func(cfg *internal.CacheConfig) internal.Cacher {
+		return &fakeCache{dsn: cfg.Dsn, closed: make(chan bool)}
+	})
+	os.Setenv("GLIB_APP1_GLIB_SUPPORTS", `{"cache":true}`)
+	os.Setenv("GLIB_APP1_GLIB_CACHE", `[{"enable":true,"alias":"c","driver":"fake","dsn":"app1"}]`)
+	os.Setenv("GLIB_APP2_GLIB_SUPPORTS", `{"cache":true}`)
+	os.Setenv("GLIB_APP2_GLIB_CACHE", `[{"enable":true,"alias":"c","driver":"fake","dsn":"app2"}]`)
+	defer func() {
+		for _, k := range []string{"GLIB_APP1_GLIB_SUPPORTS", "GLIB_APP1_GLIB_CACHE", "GLIB_APP2_GLIB_SUPPORTS", "GLIB_APP2_GLIB_CACHE"} {
+			os.Unsetenv(k)
+		}
+	}()
+
+	app1, err := New(WithConfigSource(NewEnvSource("GLIB_APP1_")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app1.Close()
+
+	app2, err := New(WithConfigSource(NewEnvSource("GLIB_APP2_")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app2.Close()
+
+	if got := app1.Cache("c").(*fakeCache).dsn; got != "app1" {
+		t.Errorf("app1 cache=%s", got)
+	}
+	if got := app2.Cache("c").(*fakeCache).dsn; got != "app2" {
+		t.Errorf("app2 cache=%s", got)
+	}
+	if _, err = LookupCache("c"); err == nil {
+		t.Error("default app should not have cache c")
+	}
+}
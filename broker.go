@@ -2,6 +2,7 @@ package glib
 
 import (
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/carltd/glib/queue"
@@ -9,70 +10,118 @@ import (
 
 type brokerConfig struct {
 	Enable bool   `json:"enable"`
-	Alias  string `json:"alias"`
-	Type   string `json:"type"`
-	Dsn    string `json:"dsn"`
-	Driver string `json:"driver"`
+	Alias  string `json:"alias" validate:"required"`
+	Type   string `json:"type" validate:"oneof=publisher consumer"`
+	Dsn    string `json:"dsn" validate:"required"`
+	Driver string `json:"driver" validate:"required"`
 }
 
-var (
-	publishers sync.Map
-	consumers  sync.Map
-)
-
 const (
 	BrokerTypePublisher = "publisher"
 	BrokerTypeConsumer  = "consumer"
 )
 
-func runBrokerManager(opts ...*brokerConfig) error {
-	for _, opt := range opts {
-		if opt.Enable {
-			switch opt.Type {
-			case BrokerTypePublisher:
-				q, err := queue.NewPublisher(opt.Driver, opt.Dsn)
-				if err != nil {
-					return fmt.Errorf("glib: broker create publisher (%s) err: %v", opt.Alias, err)
-				}
-				publishers.Store(opt.Alias, q)
-			case BrokerTypeConsumer:
-				q, err := queue.NewConsumer(opt.Driver, opt.Dsn)
-				if err != nil {
-					return fmt.Errorf("glib: broker create consumer (%s) err: %v", opt.Alias, err)
-				}
-				consumers.Store(opt.Alias, q)
-			default:
-				return fmt.Errorf("glib: invalid broker type(%s)", opt.Type)
-			}
-
+func brokerDriverRegistered(name string) bool {
+	for _, d := range queue.Drivers() {
+		if d == name {
+			return true
 		}
 	}
-	return nil
+	return false
 }
 
-func closeBroker() {
-	publishers.Range(func(key, value interface{}) bool {
-		_ = value.(queue.Publisher).Close()
-		return true
-	})
-	consumers.Range(func(key, value interface{}) bool {
-		_ = value.(queue.Consumer).Close()
-		return true
-	})
+// Publisher will return the publisher of alias, panic if it's not exists
+func Publisher(alias string) queue.Publisher {
+	return defaultApp.Publisher(alias)
 }
 
-func Publisher(alias string) queue.Publisher {
-	eg, ok := publishers.Load(alias)
+// LookupPublisher is like Publisher, but returns *ErrAliasNotConfigured if it's not exists
+func LookupPublisher(alias string) (queue.Publisher, error) {
+	return defaultApp.LookupPublisher(alias)
+}
+
+// Consumer will return the consumer of alias, panic if it's not exists
+func Consumer(alias string) queue.Consumer {
+	return defaultApp.Consumer(alias)
+}
+
+// LookupConsumer is like Consumer, but returns *ErrAliasNotConfigured if it's not exists
+func LookupConsumer(alias string) (queue.Consumer, error) {
+	return defaultApp.LookupConsumer(alias)
+}
+
+// Publisher - see the package function Publisher
+func (a *App) Publisher(alias string) queue.Publisher {
+	p, err := a.LookupPublisher(alias)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LookupPublisher - see the package function LookupPublisher
+func (a *App) LookupPublisher(alias string) (queue.Publisher, error) {
+	eg, ok := a.publishers.Load(alias)
 	if !ok {
-		panic(fmt.Errorf("glib: broker publisher[%s] not configed", alias))
+		return nil, &ErrAliasNotConfigured{Kind: KindPublisher, Alias: alias}
 	}
-	return eg.(queue.Publisher)
+	return eg.(queue.Publisher), nil
 }
 
-func Consumer(alias string) queue.Consumer {
-	eg, ok := consumers.Load(alias)
+// Consumer - see the package function Consumer
+func (a *App) Consumer(alias string) queue.Consumer {
+	c, err := a.LookupConsumer(alias)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LookupConsumer - see the package function LookupConsumer
+func (a *App) LookupConsumer(alias string) (queue.Consumer, error) {
+	eg, ok := a.consumers.Load(alias)
 	if !ok {
-		panic(fmt.Errorf("glib: broker consumer[%s] not configed", alias))
+		return nil, &ErrAliasNotConfigured{Kind: KindConsumer, Alias: alias}
+	}
+	return eg.(queue.Consumer), nil
+}
+
+func (a *App) runBrokerManager(opts ...*brokerConfig) error {
+	for _, opt := range opts {
+		if opt.Enable {
+			q, err := openBroker(opt)
+			if err != nil {
+				return err
+			}
+			a.brokerStore(opt.Type).Store(opt.Alias, q)
+		}
+	}
+	return nil
+}
+
+// openBroker returns a queue.Publisher or queue.Consumer by the type
+func openBroker(opt *brokerConfig) (io.Closer, error) {
+	switch opt.Type {
+	case BrokerTypePublisher:
+		q, err := queue.NewPublisher(opt.Driver, opt.Dsn)
+		if err != nil {
+			return nil, fmt.Errorf("glib: broker create publisher (%s) err: %v", opt.Alias, err)
+		}
+		return q, nil
+	case BrokerTypeConsumer:
+		q, err := queue.NewConsumer(opt.Driver, opt.Dsn)
+		if err != nil {
+			return nil, fmt.Errorf("glib: broker create consumer (%s) err: %v", opt.Alias, err)
+		}
+		return q, nil
+	default:
+		return nil, fmt.Errorf("glib: invalid broker type(%s)", opt.Type)
+	}
+}
+
+func (a *App) brokerStore(typ string) *sync.Map {
+	if typ == BrokerTypeConsumer {
+		return &a.consumers
 	}
-	return eg.(queue.Consumer)
+	return &a.publishers
 }
@@ -1,36 +1,57 @@
 package glib
 
 import (
-	"context"
 	"fmt"
-	"sync"
 
 	"github.com/carltd/glib/internal"
 )
 
-var (
-	caches sync.Map
-)
-
+// Cache will return the cacher of alias, panic if it's not exists
 func Cache(alias string) internal.Cacher {
-	c, ok := caches.Load(alias)
+	return defaultApp.Cache(alias)
+}
+
+// LookupCache is like Cache, but returns *ErrAliasNotConfigured if it's not exists
+func LookupCache(alias string) (internal.Cacher, error) {
+	return defaultApp.LookupCache(alias)
+}
+
+// Cache - see the package function Cache
+func (a *App) Cache(alias string) internal.Cacher {
+	c, err := a.LookupCache(alias)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LookupCache - see the package function LookupCache
+func (a *App) LookupCache(alias string) (internal.Cacher, error) {
+	c, ok := a.caches.Load(alias)
 	if !ok {
-		panic(fmt.Errorf("glib: cache[%s] not configed", alias))
+		return nil, &ErrAliasNotConfigured{Kind: KindCache, Alias: alias}
 	}
-	return c.(internal.Cacher)
+	return c.(internal.Cacher), nil
 }
 
-func runCacheManger(ctx context.Context, opts ...*internal.CacheConfig) error {
+func (a *App) runCacheManger(opts ...*internal.CacheConfig) error {
 
 	for _, opt := range opts {
 		if opt.Enable {
-			cacheCreator, ok := internal.CacheDriver(opt.Driver)
-			if ok {
-				caches.Store(opt.Alias, cacheCreator(opt))
-			} else {
-				panic(fmt.Errorf("glib: cache[%s] init err", opt.Alias))
+			c, err := openCache(opt)
+			if err != nil {
+				return err
 			}
+			a.caches.Store(opt.Alias, c)
 		}
 	}
 	return nil
 }
+
+func openCache(opt *internal.CacheConfig) (internal.Cacher, error) {
+	cacheCreator, ok := internal.CacheDriver(opt.Driver)
+	if !ok {
+		return nil, fmt.Errorf("glib: cache[%s] init err: unknown driver %q", opt.Alias, opt.Driver)
+	}
+	return cacheCreator(opt), nil
+}
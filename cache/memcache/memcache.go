@@ -20,6 +20,15 @@ func newMemCache(config *CacheConfig) Cacher {
 	}
 }
 
+// Ping gets a key which is never set, the server is ok if it's a cache miss
+func (c *MCache) Ping() error {
+	_, err := c.conn.Get("glib:ping")
+	if err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
 func (c *MCache) Touch(key string, timeout time.Duration) error {
 	return c.conn.Touch(key, int32(timeout/time.Second))
 }
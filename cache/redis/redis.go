@@ -55,6 +55,13 @@ func NewRedisCache(config *internal.CacheConfig) internal.Cacher {
 	return c
 }
 
+func (c *RCache) Ping() (err error) {
+	conn := c.p.Get()
+	_, err = conn.Do("PING")
+	conn.Close()
+	return err
+}
+
 func (c *RCache) Get(key string) (result interface{}, err error) {
 	conn := c.p.Get()
 	result, err = conn.Do("GET", key)
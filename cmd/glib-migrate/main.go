@@ -0,0 +1,59 @@
+// glib-migrate runs the sql migrations in a directory, see package migrate.
+//
+// usage:
+//
+//	glib-migrate -driver mysql -dsn 'root:@tcp(127.0.0.1:3306)/test' -dir ./migrations up
+//	glib-migrate -driver mysql -dsn 'root:@tcp(127.0.0.1:3306)/test' -dir ./migrations down 1
+//	glib-migrate -driver mysql -dsn 'root:@tcp(127.0.0.1:3306)/test' -dir ./migrations status
+//
+// the migrations written in go can be run by the service's binary with migrate.Command.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jinzhu/gorm"
+
+	_ "github.com/carltd/glib/dialects/mssql"
+	_ "github.com/carltd/glib/dialects/mysql"
+	_ "github.com/carltd/glib/dialects/postgres"
+	_ "github.com/carltd/glib/dialects/sqlite"
+	"github.com/carltd/glib/migrate"
+)
+
+func main() {
+	var (
+		driver = flag.String("driver", "mysql", "sql driver: mysql, postgres, mssql or sqlite3")
+		dsn    = flag.String("dsn", os.Getenv("GLIB_MIGRATE_DSN"), "dsn of the database")
+		dir    = flag.String("dir", "./migrations", "directory of the sql files")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] up|down [n]|status\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(*driver, *dsn, *dir, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "glib-migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(driver, dsn, dir string, args []string) error {
+	// the alias is only used to group the migrations
+	const alias = "cli"
+	if err := migrate.LoadDir(alias, dir); err != nil {
+		return err
+	}
+
+	db, err := gorm.Open(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SingularTable(true)
+
+	return migrate.Command(db, alias, args, os.Stdout)
+}
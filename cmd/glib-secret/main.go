@@ -0,0 +1,111 @@
+// glib-secret encrypts and decrypts the config values for glib's config center.
+//
+// usage:
+//
+//	glib-secret genkey > glib.key
+//	glib-secret -keyfile glib.key encrypt 'root:pass@tcp(127.0.0.1:3306)/test'
+//	glib-secret -keyfile glib.key decrypt 'enc:v1:...'
+//
+// the value is read from stdin if it's not given in args,
+// GLIB_SECRET_KEY(base64) or GLIB_SECRET_KEY_FILE are used if no key given.
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/carltd/glib/internal"
+)
+
+func main() {
+	var (
+		key     = flag.String("key", os.Getenv("GLIB_SECRET_KEY"), "AES key in base64")
+		keyFile = flag.String("keyfile", os.Getenv("GLIB_SECRET_KEY_FILE"), "file contains the AES key in base64")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] genkey|encrypt|decrypt [value]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(flag.Args(), *key, *keyFile); err != nil {
+		fmt.Fprintln(os.Stderr, "glib-secret:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, key, keyFile string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "genkey":
+		k, err := internal.NewSecretKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(k))
+		return nil
+	case "encrypt", "decrypt":
+	default:
+		// checked before reading the key and stdin
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	k, err := readKey(key, keyFile)
+	if err != nil {
+		return err
+	}
+	value, err := readValue(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "encrypt" {
+		s, err := internal.EncryptSecret(k, []byte(value))
+		if err != nil {
+			return err
+		}
+		fmt.Println(s)
+		return nil
+	}
+	plain, err := internal.DecryptSecret(k, value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(plain))
+	return nil
+}
+
+func readKey(key, keyFile string) ([]byte, error) {
+	if key != "" {
+		return internal.ParseSecretKey(key)
+	}
+	if keyFile == "" {
+		return nil, errors.New("no key given, use -key or -keyfile")
+	}
+	buf, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ParseSecretKey(string(buf))
+}
+
+func readValue(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+	s, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && s == "" {
+		return "", errors.New("no value given")
+	}
+	return strings.TrimRight(s, "\r\n"), nil
+}
@@ -1,20 +1,41 @@
 package glib
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 )
 
+// ConfigObject is a value in the config center, the typed accessors return def
+// if the value is empty or can't be converted.
 type ConfigObject interface {
 	StringSlice(def []string) []string
 	StringMap(def map[string]string) map[string]string
 	Scan(val interface{}) error
 	Bytes() []byte
+
+	String(def string) string
+	Int(def int) int
+	Float(def float64) float64
+	Bool(def bool) bool
+	// json number is in seconds like other glib's ttl, string is parsed by time.ParseDuration
+	Duration(def time.Duration) time.Duration
+	// json number is unix timestamp in seconds, string is in RFC3339 format
+	Time(def time.Time) time.Time
 }
 
+// ConfigWatcher will be called when the value of the watched key changed,
+// `old` or `new` is an empty ConfigObject if the key was added or deleted.
+type ConfigWatcher func(old, new ConfigObject)
+
 type configObject []byte
 
 func (o configObject) StringSlice(def []string) []string {
@@ -34,14 +55,105 @@ func (o configObject) StringMap(def map[string]string) map[string]string {
 func (o configObject) Scan(val interface{}) error { return json.Unmarshal(o, val) }
 func (o configObject) Bytes() []byte              { return o }
 
+// scalar returns the text of a json string or number, and the non json value as it is
+func (o configObject) scalar() (text string, isNumber bool) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(o))
+	d.UseNumber()
+	if d.Decode(&v) != nil {
+		return string(bytes.TrimSpace(o)), false
+	}
+	switch t := v.(type) {
+	case string:
+		return t, false
+	case json.Number:
+		return t.String(), true
+	case bool:
+		return strconv.FormatBool(t), false
+	}
+	return string(o), false
+}
+
+func (o configObject) String(def string) string {
+	if len(o) == 0 {
+		return def
+	}
+	s, _ := o.scalar()
+	return s
+}
+
+func (o configObject) Int(def int) int {
+	s, _ := o.scalar()
+	if i, err := strconv.Atoi(s); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return int(f)
+	}
+	return def
+}
+
+func (o configObject) Float(def float64) float64 {
+	s, _ := o.scalar()
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return def
+}
+
+func (o configObject) Bool(def bool) bool {
+	s, _ := o.scalar()
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b
+	}
+	return def
+}
+
+func (o configObject) Duration(def time.Duration) time.Duration {
+	s, isNumber := o.scalar()
+	if isNumber {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return time.Duration(f * float64(time.Second))
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d
+	}
+	return def
+}
+
+func (o configObject) Time(def time.Time) time.Time {
+	s, isNumber := o.scalar()
+	if isNumber {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(i, 0)
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	return def
+}
+
 type configCenter struct {
-	opts   options
-	rawMap map[string][]byte
+	opts      options
+	sources   []ConfigSource
+	secretKey []byte
+
+	mu       sync.RWMutex
+	layers   []map[string][]byte // raw values of every source, same order as sources
+	rawMap   map[string][]byte   // merged values of all layers
+	watchers map[string][]ConfigWatcher
+
+	clientOnce sync.Once
+	client     *api.Client
+	clientErr  error
 }
 
 func newConfigCenter(opts ...option) (*configCenter, error) {
 	cc := &configCenter{
-		rawMap: make(map[string][]byte),
+		rawMap:   make(map[string][]byte),
+		watchers: make(map[string][]ConfigWatcher),
 	}
 	err := cc.Init(opts...)
 	return cc, err
@@ -51,44 +163,247 @@ func (cc *configCenter) Init(opts ...option) error {
 
 	cc.opts = newOptions(opts...)
 
-	cfg := api.DefaultConfig()
-	cfg.Address = cc.opts.DiscoverAddr
-	client, err := api.NewClient(cfg)
-	if err != nil {
-		return err
+	cc.sources = cc.opts.ConfigSources
+	if len(cc.sources) == 0 {
+		src, err := NewConsulSource(cc.opts.DiscoverAddr)
+		if err != nil {
+			return err
+		}
+		cc.sources = []ConfigSource{src}
 	}
 
-	kv, _, err := client.KV().List(cc.opts.ServiceDomain, nil)
+	key, err := loadSecretKey(cc.opts)
 	if err != nil {
-		return err
+		return fmt.Errorf("glib: load secret key err: %v", err)
 	}
+	cc.secretKey = key
 
-	if kv != nil && len(kv) > 0 {
-		for _, v := range kv {
-			k := strings.TrimPrefix(strings.TrimPrefix(v.Key, cc.opts.ServiceDomain), "/")
-			cc.rawMap[k] = v.Value
+	layers := make([]map[string][]byte, len(cc.sources))
+	for i, src := range cc.sources {
+		m, err := src.Read(cc.opts.ServiceDomain)
+		if err != nil {
+			return fmt.Errorf("glib: read config from %s err: %v", src.Name(), err)
+		}
+		if layers[i], err = decryptValues(cc.secretKey, m); err != nil {
+			return err
 		}
 	}
 
+	cc.mu.Lock()
+	cc.layers = layers
+	cc.rawMap = mergeLayers(layers)
+	cc.mu.Unlock()
+
 	return nil
 }
 
+// Watch keeps rawMap current until ctx is done,
+// the watchers of the changed keys will be called.
+func (cc *configCenter) Watch(ctx context.Context) {
+	for i, src := range cc.sources {
+		go func(i int, src ConfigSource) {
+			err := src.Watch(ctx, cc.opts.ServiceDomain, func(m map[string][]byte) {
+				m, err := decryptValues(cc.secretKey, m)
+				if err != nil {
+					log.Printf("glib: ignore config changes from %s: %v", src.Name(), err)
+					return
+				}
+				cc.update(i, m)
+			})
+			if err != nil {
+				log.Printf("glib: watch config from %s err: %v", src.Name(), err)
+			}
+		}(i, src)
+	}
+}
+
+func (cc *configCenter) update(layer int, m map[string][]byte) {
+	type change struct {
+		old, new configObject
+		fns      []ConfigWatcher
+	}
+
+	cc.mu.Lock()
+	cc.layers[layer] = m
+	oldMap := cc.rawMap
+	newMap := mergeLayers(cc.layers)
+	cc.rawMap = newMap
+
+	changes := make([]change, 0)
+	for k, fns := range cc.watchers {
+		ov, _ := lookupKeyPath(oldMap, k)
+		nv, _ := lookupKeyPath(newMap, k)
+		if !bytes.Equal(ov, nv) {
+			changes = append(changes, change{configObject(ov), configObject(nv), append([]ConfigWatcher(nil), fns...)})
+		}
+	}
+	cc.mu.Unlock()
+
+	for _, c := range changes {
+		for _, fn := range c.fns {
+			fn(c.old, c.new)
+		}
+	}
+}
+
+// consulClient returns the client of the consul source at DiscoverAddr,
+// a new one is created if there is not, it's shared by registry, resolver and lock.
+func (cc *configCenter) consulClient() (*api.Client, error) {
+	cc.clientOnce.Do(func() {
+		for _, src := range cc.sources {
+			if s, ok := src.(*consulSource); ok && s.addr == cc.opts.DiscoverAddr {
+				cc.client = s.client
+				return
+			}
+		}
+		cfg := api.DefaultConfig()
+		cfg.Address = cc.opts.DiscoverAddr
+		cc.client, cc.clientErr = api.NewClient(cfg)
+	})
+	return cc.client, cc.clientErr
+}
+
+// Origin returns the names of sources which have the key path,
+// from the lowest precedence to the highest, the last one wins.
+func (cc *configCenter) Origin(keyPath string) []string {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	names := make([]string, 0)
+	for i, layer := range cc.layers {
+		if _, ok := lookupKeyPath(layer, keyPath); ok {
+			names = append(names, cc.sources[i].Name())
+		}
+	}
+	return names
+}
+
+// lookupKeyPath finds the value of keyPath, it's a key in m or a key followed by
+// the path into its json value separated by `/` or `.`,
+// eg: `payment/limits.daily` is `daily` of `limits` in key `payment`,
+// or `daily` in key `payment/limits`, the longer key is preferred.
+func lookupKeyPath(m map[string][]byte, keyPath string) ([]byte, bool) {
+	if v, ok := m[keyPath]; ok {
+		return v, true
+	}
+
+	for i := len(keyPath) - 1; i > 0; i-- {
+		if keyPath[i] != '/' && keyPath[i] != '.' {
+			continue
+		}
+		v, ok := m[keyPath[:i]]
+		if !ok {
+			continue
+		}
+		path := strings.FieldsFunc(keyPath[i+1:], func(r rune) bool { return r == '/' || r == '.' })
+		return lookupJSONPath(v, path)
+	}
+	return nil, false
+}
+
+func lookupJSONPath(buf []byte, path []string) ([]byte, bool) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	if d.Decode(&v) != nil {
+		return nil, false
+	}
+
+	for _, p := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = t[p]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			v = t[i]
+		default:
+			return nil, false
+		}
+	}
+
+	ret, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return ret, true
+}
+
+// mergeLayers merges the layers in order, a value of the later layer overrides the former one,
+// json objects of the same key are merged deeply.
+func mergeLayers(layers []map[string][]byte) map[string][]byte {
+	m := make(map[string][]byte)
+	for _, layer := range layers {
+		for k, v := range layer {
+			if ov, ok := m[k]; ok {
+				m[k] = mergeValue(ov, v)
+			} else {
+				m[k] = v
+			}
+		}
+	}
+	return m
+}
+
+func mergeValue(base, override []byte) []byte {
+	var b, o map[string]interface{}
+	if json.Unmarshal(base, &b) != nil || json.Unmarshal(override, &o) != nil || b == nil || o == nil {
+		return override
+	}
+	buf, err := json.Marshal(mergeObject(b, o))
+	if err != nil {
+		return override
+	}
+	return buf
+}
+
+func mergeObject(base, override map[string]interface{}) map[string]interface{} {
+	for k, v := range override {
+		bv, bok := base[k].(map[string]interface{})
+		ov, ook := v.(map[string]interface{})
+		if bok && ook {
+			base[k] = mergeObject(bv, ov)
+		} else {
+			base[k] = v
+		}
+	}
+	return base
+}
+
+// AddWatcher register a watcher for the key
+func (cc *configCenter) AddWatcher(key string, fn ConfigWatcher) {
+	cc.mu.Lock()
+	cc.watchers[key] = append(cc.watchers[key], fn)
+	cc.mu.Unlock()
+}
+
 func (cc *configCenter) String(key, defValue string) string {
-	if val, ok := cc.rawMap[key]; ok {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if val, ok := lookupKeyPath(cc.rawMap, key); ok {
 		return string(val)
 	}
 	return defValue
 }
 
 func (cc *configCenter) Load(key string, v interface{}) error {
-	if val, ok := cc.rawMap[key]; ok {
+	cc.mu.RLock()
+	val, ok := lookupKeyPath(cc.rawMap, key)
+	cc.mu.RUnlock()
+	if ok {
 		return json.Unmarshal(val, v)
 	}
 	return fmt.Errorf("%s/%s not found", cc.opts.ServiceDomain, key)
 }
 
 func (cc *configCenter) Raw(key string) ConfigObject {
-	if val, ok := cc.rawMap[key]; ok {
+	cc.mu.RLock()
+	defer cc.mu.RUnlock()
+	if val, ok := lookupKeyPath(cc.rawMap, key); ok {
 		return configObject(val)
 	}
 	return configObject{}
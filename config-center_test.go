@@ -0,0 +1,190 @@
+package glib
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/carltd/glib/internal"
+)
+
+func TestConfigCenter_Layers(t *testing.T) {
+	os.Setenv("GLIB_TEST_PAYMENT", `{"limits":{"daily":200}}`)
+	defer os.Unsetenv("GLIB_TEST_PAYMENT")
+
+	fileSrc := NewFileSource("testdata/conf")
+	envSrc := NewEnvSource("GLIB_TEST_")
+	cc, err := newConfigCenter(WithConfigSource(fileSrc), WithConfigSource(envSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	if err = cc.Load("payment", &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"limits":   map[string]interface{}{"daily": 200.0, "monthly": 3000.0},
+		"currency": "CNY",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("payment=%v, want=%v", got, want)
+	}
+
+	if origin := cc.Origin("payment"); !reflect.DeepEqual(origin, []string{fileSrc.Name(), envSrc.Name()}) {
+		t.Errorf("origin of payment=%v", origin)
+	}
+	if origin := cc.Origin("glib-supports"); !reflect.DeepEqual(origin, []string{fileSrc.Name()}) {
+		t.Errorf("origin of glib-supports=%v", origin)
+	}
+}
+
+func TestConfigCenter_Watcher(t *testing.T) {
+	cc, err := newConfigCenter(WithConfigSource(NewFileSource("testdata/conf")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	cc.AddWatcher("payment", func(old, new ConfigObject) {
+		var v map[string]interface{}
+		if err := json.Unmarshal(new.Bytes(), &v); err != nil {
+			t.Error(err)
+		}
+		calls = append(calls, v["currency"].(string))
+	})
+	cc.AddWatcher("glib-supports", func(old, new ConfigObject) {
+		t.Error("glib-supports not changed, but the watcher was called")
+	})
+
+	m := map[string][]byte{
+		"glib-supports": cc.Raw("glib-supports").Bytes(),
+		"payment":       []byte(`{"currency":"USD"}`),
+	}
+	cc.update(0, m)
+	cc.update(0, m)
+
+	if !reflect.DeepEqual(calls, []string{"USD"}) {
+		t.Errorf("watcher calls=%v", calls)
+	}
+}
+
+func TestMergeValue(t *testing.T) {
+	tests := []struct{ base, override, want string }{
+		{`{"a":1,"b":{"c":2}}`, `{"b":{"d":3}}`, `{"a":1,"b":{"c":2,"d":3}}`},
+		{`{"a":1}`, `[1,2]`, `[1,2]`},
+		{`[1,2]`, `{"a":1}`, `{"a":1}`},
+		{`plain`, `{"a":1}`, `{"a":1}`},
+		{`{"a":1}`, `plain`, `plain`},
+	}
+	for _, tt := range tests {
+		if got := string(mergeValue([]byte(tt.base), []byte(tt.override))); got != tt.want {
+			t.Errorf("merge(%s, %s)=%s, want=%s", tt.base, tt.override, got, tt.want)
+		}
+	}
+}
+
+func TestConfigObject_Typed(t *testing.T) {
+	def := time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		raw      string
+		str      string
+		i        int
+		f        float64
+		b        bool
+		duration time.Duration
+		time     time.Time
+	}{
+		{``, "def", -1, -1, false, time.Minute, def},
+		{`"abc"`, "abc", -1, -1, false, time.Minute, def},
+		{`30`, "30", 30, 30, false, 30 * time.Second, time.Unix(30, 0)},
+		{`1.5`, "1.5", 1, 1.5, false, 1500 * time.Millisecond, def},
+		{`"42"`, "42", 42, 42, false, time.Minute, def},
+		{`true`, "true", -1, -1, true, time.Minute, def},
+		{`"1m30s"`, "1m30s", -1, -1, false, 90 * time.Second, def},
+		{`"2019-09-07T10:00:00Z"`, "2019-09-07T10:00:00Z", -1, -1, false, time.Minute, time.Date(2019, 9, 7, 10, 0, 0, 0, time.UTC)},
+		{`plain text`, "plain text", -1, -1, false, time.Minute, def},
+	}
+	for _, tt := range tests {
+		o := configObject(tt.raw)
+		if got := o.String("def"); got != tt.str {
+			t.Errorf("String(%s)=%v, want=%v", tt.raw, got, tt.str)
+		}
+		if got := o.Int(-1); got != tt.i {
+			t.Errorf("Int(%s)=%v, want=%v", tt.raw, got, tt.i)
+		}
+		if got := o.Float(-1); got != tt.f {
+			t.Errorf("Float(%s)=%v, want=%v", tt.raw, got, tt.f)
+		}
+		if got := o.Bool(false); got != tt.b {
+			t.Errorf("Bool(%s)=%v, want=%v", tt.raw, got, tt.b)
+		}
+		if got := o.Duration(time.Minute); got != tt.duration {
+			t.Errorf("Duration(%s)=%v, want=%v", tt.raw, got, tt.duration)
+		}
+		if got := o.Time(def); !got.Equal(tt.time) {
+			t.Errorf("Time(%s)=%v, want=%v", tt.raw, got, tt.time)
+		}
+	}
+}
+
+func TestLookupKeyPath(t *testing.T) {
+	m := map[string][]byte{
+		"payment":        []byte(`{"limits":{"daily":100},"channels":["alipay","wechat"]}`),
+		"payment/limits": []byte(`{"daily":200}`),
+		"a.b":            []byte(`1`),
+	}
+	tests := []struct {
+		keyPath string
+		want    string
+		ok      bool
+	}{
+		{"payment/limits.daily", "200", true},
+		{"payment.limits.daily", "100", true},
+		{"payment/channels/1", `"wechat"`, true},
+		{"payment/channels.2", "", false},
+		{"payment/currency", "", false},
+		{"a.b", "1", true},
+		{"none", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := lookupKeyPath(m, tt.keyPath)
+		if ok != tt.ok || string(got) != tt.want {
+			t.Errorf("lookup(%s)=%s,%v want=%s,%v", tt.keyPath, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestConfigCenter_Secret(t *testing.T) {
+	key, _ := internal.NewSecretKey()
+	dsn, err := internal.EncryptSecret(key, []byte("root:pass@tcp(127.0.0.1:3306)/test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := internal.EncryptSecret(key, []byte("abc"))
+
+	os.Setenv("GLIB_SECRET_DB", `[{"alias":"db1","dsn":"`+dsn+`","maxOpen":10}]`)
+	os.Setenv("GLIB_SECRET_TOKEN", token)
+	defer os.Unsetenv("GLIB_SECRET_DB")
+	defer os.Unsetenv("GLIB_SECRET_TOKEN")
+
+	if _, err = newConfigCenter(WithConfigSource(NewEnvSource("GLIB_SECRET_"))); err == nil {
+		t.Error("no key, want err")
+	}
+
+	cc, err := newConfigCenter(WithConfigSource(NewEnvSource("GLIB_SECRET_")), WithSecretKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cc.Raw("db.0.dsn").String(""); got != "root:pass@tcp(127.0.0.1:3306)/test" {
+		t.Errorf("dsn=%s", got)
+	}
+	if got := cc.Raw("db.0.maxOpen").Bytes(); string(got) != "10" {
+		t.Errorf("maxOpen=%s", got)
+	}
+	if got := cc.String("token", ""); got != "abc" {
+		t.Errorf("token=%s", got)
+	}
+}
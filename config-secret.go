@@ -0,0 +1,109 @@
+package glib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/carltd/glib/internal"
+)
+
+// environment variables of the secret key, used if it's not given by options
+const (
+	envSecretKey     = "GLIB_SECRET_KEY"
+	envSecretKeyFile = "GLIB_SECRET_KEY_FILE"
+)
+
+var errNoSecretKey = errors.New("encrypted value found, but no secret key given (WithSecretKey or " + envSecretKey + ")")
+
+// loadSecretKey returns the key to decrypt config values, nil if no key given.
+// the priority is WithSecretKey, WithSecretKeyFile, GLIB_SECRET_KEY, GLIB_SECRET_KEY_FILE.
+func loadSecretKey(opts options) ([]byte, error) {
+	var (
+		key  = os.Getenv(envSecretKey)
+		file = os.Getenv(envSecretKeyFile)
+	)
+
+	switch {
+	case len(opts.SecretKey) > 0:
+		return opts.SecretKey, nil
+	case opts.SecretKeyFile != "":
+		file = opts.SecretKeyFile
+	case key != "":
+		return internal.ParseSecretKey(key)
+	case file == "":
+		return nil, nil
+	}
+
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ParseSecretKey(string(buf))
+}
+
+// decryptValues decrypts the encrypted values in m, include the json strings in values
+func decryptValues(key []byte, m map[string][]byte) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(m))
+	for k, v := range m {
+		val, err := decryptValue(key, v)
+		if err != nil {
+			return nil, fmt.Errorf("glib: decrypt %s err: %v", k, err)
+		}
+		out[k] = val
+	}
+	return out, nil
+}
+
+func decryptValue(key []byte, v []byte) ([]byte, error) {
+	if !bytes.Contains(v, []byte(internal.SecretPrefix)) {
+		return v, nil
+	}
+	if key == nil {
+		return nil, errNoSecretKey
+	}
+
+	if s := strings.TrimSpace(string(v)); internal.IsSecret(s) {
+		return internal.DecryptSecret(key, s)
+	}
+
+	var obj interface{}
+	d := json.NewDecoder(bytes.NewReader(v))
+	d.UseNumber()
+	if d.Decode(&obj) != nil {
+		return v, nil
+	}
+	obj, err := decryptJSON(key, obj)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+func decryptJSON(key []byte, v interface{}) (interface{}, error) {
+	var err error
+	switch t := v.(type) {
+	case string:
+		if internal.IsSecret(t) {
+			plain, err := internal.DecryptSecret(key, t)
+			return string(plain), err
+		}
+	case map[string]interface{}:
+		for k, val := range t {
+			if t[k], err = decryptJSON(key, val); err != nil {
+				return nil, err
+			}
+		}
+	case []interface{}:
+		for i, val := range t {
+			if t[i], err = decryptJSON(key, val); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
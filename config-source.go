@@ -0,0 +1,247 @@
+package glib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// max time of a consul blocking query
+	consulWatchWaitTime = 5 * time.Minute
+	// delay before retry when the consul query failed
+	consulWatchRetryDelay = 3 * time.Second
+	// interval of checking the files changed
+	fileWatchInterval = 5 * time.Second
+)
+
+// ConfigSource is a backend of the config center
+type ConfigSource interface {
+	// Name of the source, eg: consul:127.0.0.1:8500, file:./conf, env:DEMO_
+	Name() string
+
+	// Read returns all key/value pairs of the domain,
+	// keys are relative to the domain, eg: glib-db
+	Read(domain string) (map[string][]byte, error)
+
+	// Watch blocks until ctx is done, fn will be called with
+	// all key/value pairs of the domain when anything changed
+	Watch(ctx context.Context, domain string, fn func(map[string][]byte)) error
+}
+
+type consulSource struct {
+	addr      string
+	client    *api.Client
+	lastIndex uint64
+}
+
+// NewConsulSource - config source of consul kv, the keys are placed under the service domain
+func NewConsulSource(addr string) (ConfigSource, error) {
+	cfg := api.DefaultConfig()
+	cfg.Address = addr
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &consulSource{addr: addr, client: client}, nil
+}
+
+func (s *consulSource) Name() string { return "consul:" + s.addr }
+
+func (s *consulSource) Read(domain string) (map[string][]byte, error) {
+	kv, meta, err := s.client.KV().List(domain, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.lastIndex = meta.LastIndex
+	return s.toRawMap(domain, kv), nil
+}
+
+func (s *consulSource) Watch(ctx context.Context, domain string, fn func(map[string][]byte)) error {
+	for {
+		q := &api.QueryOptions{WaitIndex: s.lastIndex, WaitTime: consulWatchWaitTime}
+		kv, meta, err := s.client.KV().List(domain, q.WithContext(ctx))
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Printf("glib: watch consul %s err: %v", domain, err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(consulWatchRetryDelay):
+			}
+			continue
+		}
+
+		// nothing changed, the blocking query just timeout
+		if meta.LastIndex == s.lastIndex {
+			continue
+		}
+
+		// the index went backwards, consul suggests to reset it
+		if meta.LastIndex < s.lastIndex {
+			s.lastIndex = 0
+		} else {
+			s.lastIndex = meta.LastIndex
+		}
+		fn(s.toRawMap(domain, kv))
+	}
+}
+
+func (s *consulSource) toRawMap(domain string, kv api.KVPairs) map[string][]byte {
+	m := make(map[string][]byte, len(kv))
+	for _, v := range kv {
+		k := strings.TrimPrefix(strings.TrimPrefix(v.Key, domain), "/")
+		m[k] = v.Value
+	}
+	return m
+}
+
+type fileSource struct {
+	dir string
+}
+
+// NewFileSource - config source of a local directory, the domain is ignored.
+// every file is a key named by its path relative to dir without extension,
+// eg: `dir/glib-db.json` is `glib-db`, `dir/payment/limits.yaml` is `payment/limits`.
+// the content of .yaml and .yml files will be converted to json.
+func NewFileSource(dir string) ConfigSource {
+	return &fileSource{dir: dir}
+}
+
+func (s *fileSource) Name() string { return "file:" + s.dir }
+
+func (s *fileSource) Read(domain string) (map[string][]byte, error) {
+	m := make(map[string][]byte)
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(rel)
+		key := filepath.ToSlash(strings.TrimSuffix(rel, ext))
+
+		switch ext {
+		case ".yaml", ".yml":
+			if buf, err = yamlToJSON(buf); err != nil {
+				return fmt.Errorf("glib: config file %s err: %v", path, err)
+			}
+		case ".json":
+		default:
+			key = filepath.ToSlash(rel)
+		}
+		m[key] = buf
+		return nil
+	})
+	return m, err
+}
+
+func (s *fileSource) Watch(ctx context.Context, domain string, fn func(map[string][]byte)) error {
+	last, _ := s.Read(domain)
+
+	t := time.NewTicker(fileWatchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+			m, err := s.Read(domain)
+			if err != nil {
+				log.Printf("glib: watch config dir %s err: %v", s.dir, err)
+				continue
+			}
+			if !reflect.DeepEqual(last, m) {
+				last = m
+				fn(m)
+			}
+		}
+	}
+}
+
+type envSource struct {
+	prefix string
+}
+
+// NewEnvSource - config source of environment variables which have the prefix, the domain is ignored.
+// the key is the variable's name without prefix, in lower case,
+// `__` replaced by `/` and `_` replaced by `-`,
+// eg: with prefix `DEMO_`, `DEMO_GLIB_DB` is `glib-db`, `DEMO_PAYMENT__LIMITS` is `payment/limits`.
+func NewEnvSource(prefix string) ConfigSource {
+	return &envSource{prefix: prefix}
+}
+
+func (s *envSource) Name() string { return "env:" + s.prefix }
+
+func (s *envSource) Read(domain string) (map[string][]byte, error) {
+	m := make(map[string][]byte)
+	for _, kv := range os.Environ() {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 || !strings.HasPrefix(pair[0], s.prefix) {
+			continue
+		}
+		key := strings.ToLower(strings.TrimPrefix(pair[0], s.prefix))
+		key = strings.Replace(key, "__", "/", -1)
+		key = strings.Replace(key, "_", "-", -1)
+		if key != "" {
+			m[key] = []byte(pair[1])
+		}
+	}
+	return m, nil
+}
+
+// environment variables can't be changed by others, nothing to watch
+func (s *envSource) Watch(ctx context.Context, domain string, fn func(map[string][]byte)) error {
+	<-ctx.Done()
+	return nil
+}
+
+func yamlToJSON(buf []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(buf, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonCompatible(v))
+}
+
+// yaml decodes mappings as map[interface{}]interface{}, which can't be encoded by json
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = jsonCompatible(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,202 @@
+package glib
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ConfigProblem is a problem of a config field
+type ConfigProblem struct {
+	// full key path in the config center, eg: com.carltd.srv.demo/glib-db
+	Key string
+	// field name in json with index, eg: [0].dsn
+	Field string
+	Msg   string
+}
+
+func (p *ConfigProblem) String() string {
+	return p.Key + p.Field + ": " + p.Msg
+}
+
+// ConfigError contains all problems found when loading glib-* keys
+type ConfigError []*ConfigProblem
+
+func (e ConfigError) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("glib: %d config problem(s) found:", len(e)))
+	for _, p := range e {
+		lines = append(lines, "\t"+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validateConfig checks the fields of v by their `validate` tag, rules are separated by comma:
+//
+//	required  - must not be zero value
+//	omitempty - skip the other rules if it's zero value
+//	min=N     - number must be >= N, length of string must be >= N
+//	oneof=a b - must be one of the space separated values
+//	url       - must be an absolute url if it's not empty
+//	unique    - must be unique in the list
+//
+// elements of a list which has `Enable` field with false are skipped.
+func validateConfig(key string, v interface{}) ConfigError {
+	var problems ConfigError
+	validateValue(key, "", reflect.ValueOf(v), &problems)
+	return problems
+}
+
+func validateValue(key, field string, v reflect.Value, problems *ConfigError) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			validateValue(key, field, v.Elem(), problems)
+		}
+	case reflect.Slice, reflect.Array:
+		seen := make(map[string]map[interface{}]int)
+		for i := 0; i < v.Len(); i++ {
+			item := reflect.Indirect(v.Index(i))
+			if item.Kind() != reflect.Struct || !configEnabled(item) {
+				continue
+			}
+			itemField := field + "[" + strconv.Itoa(i) + "]"
+			validateValue(key, itemField, item, problems)
+			validateUnique(key, itemField, i, item, seen, problems)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := configFieldName(f)
+			if name == "" {
+				continue
+			}
+			tag := f.Tag.Get("validate")
+			if hasRule(tag, "omitempty") && isZero(v.Field(i)) {
+				continue
+			}
+			for _, rule := range strings.Split(tag, ",") {
+				if msg := checkRule(rule, v.Field(i)); msg != "" {
+					*problems = append(*problems, &ConfigProblem{Key: key, Field: field + "." + name, Msg: msg})
+				}
+			}
+			if k := f.Type.Kind(); k == reflect.Struct || k == reflect.Slice || k == reflect.Ptr {
+				validateValue(key, field+"."+name, v.Field(i), problems)
+			}
+		}
+	}
+}
+
+func validateUnique(key, field string, index int, item reflect.Value, seen map[string]map[interface{}]int, problems *ConfigError) {
+	t := item.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !hasRule(f.Tag.Get("validate"), "unique") {
+			continue
+		}
+		name := configFieldName(f)
+		val := item.Field(i).Interface()
+		if seen[name] == nil {
+			seen[name] = make(map[interface{}]int)
+		}
+		if prev, dup := seen[name][val]; dup {
+			*problems = append(*problems, &ConfigProblem{
+				Key: key, Field: field + "." + name,
+				Msg: fmt.Sprintf("duplicated with [%d].%s (%v)", prev, name, val),
+			})
+			continue
+		}
+		seen[name][val] = index
+	}
+}
+
+func checkRule(rule string, v reflect.Value) string {
+	rule = strings.TrimSpace(rule)
+	switch {
+	case rule == "required":
+		if isZero(v) {
+			return "required"
+		}
+	case strings.HasPrefix(rule, "min="):
+		min, err := strconv.ParseFloat(strings.TrimPrefix(rule, "min="), 64)
+		if err != nil {
+			return "bad rule " + rule
+		}
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if float64(v.Int()) < min {
+				return fmt.Sprintf("must be >= %v, got %v", min, v.Int())
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if float64(v.Uint()) < min {
+				return fmt.Sprintf("must be >= %v, got %v", min, v.Uint())
+			}
+		case reflect.Float32, reflect.Float64:
+			if v.Float() < min {
+				return fmt.Sprintf("must be >= %v, got %v", min, v.Float())
+			}
+		case reflect.String, reflect.Slice, reflect.Map:
+			if float64(v.Len()) < min {
+				return fmt.Sprintf("length must be >= %v, got %v", min, v.Len())
+			}
+		}
+	case rule == "url":
+		if s := v.String(); s != "" {
+			if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Sprintf("must be an url like http://host:port/path, got %q", s)
+			}
+		}
+	case strings.HasPrefix(rule, "oneof="):
+		values := strings.Fields(strings.TrimPrefix(rule, "oneof="))
+		got := fmt.Sprint(v.Interface())
+		for _, want := range values {
+			if got == want {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s], got %q", strings.Join(values, " "), got)
+	}
+	return ""
+}
+
+func hasRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		if strings.TrimSpace(r) == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+func configEnabled(v reflect.Value) bool {
+	f := v.FieldByName("Enable")
+	return !f.IsValid() || f.Kind() != reflect.Bool || f.Bool()
+}
+
+// name of the field in json, empty if it's ignored or unexported
+func configFieldName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return f.Name
+}
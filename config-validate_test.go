@@ -0,0 +1,45 @@
+package glib
+
+import (
+	"reflect"
+	"testing"
+
+	gtrace "github.com/carltd/glib/trace"
+)
+
+func TestValidateConfig(t *testing.T) {
+	dbs := []*dbConfig{
+		{Enable: true, Alias: "db1", Driver: "mysql", Dsn: "root:@tcp(127.0.0.1:3306)/test", MaxIdle: 1, MaxOpen: 10},
+		{Enable: true, Alias: "db1", Driver: "mysql", MaxOpen: -1, Policy: "weighted"},
+		{Enable: false, Alias: "db1"},
+	}
+	got := make([]string, 0)
+	for _, p := range validateConfig("srv/glib-db", &dbs) {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"srv/glib-db[1].dsn: required",
+		"srv/glib-db[1].maxOpen: must be >= 0, got -1",
+		`srv/glib-db[1].policy: must be one of [round-robin random least-recent-failure], got "weighted"`,
+		"srv/glib-db[1].alias: duplicated with [0].alias (db1)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems=%q, want=%q", got, want)
+	}
+
+	brokers := []*brokerConfig{{Enable: true, Alias: "b", Type: "pub", Dsn: "redis://", Driver: "redis"}}
+	if ps := validateConfig("srv/glib-broker", &brokers); len(ps) != 1 || ps[0].Field != "[0].type" {
+		t.Errorf("problems=%v", ps)
+	}
+
+	// 0 is the default of the drivers
+	mgos := []*mgoConfig{{Enable: true, Alias: "mgo", Dsn: "mongodb://127.0.0.1:27017"}}
+	if ps := validateConfig("srv/glib-mgo", &mgos); len(ps) != 0 {
+		t.Errorf("problems=%v", ps)
+	}
+
+	tracer := &gtrace.TracerConfig{Address: "host:9411"}
+	if ps := validateConfig("srv/glib-tracer", tracer); len(ps) != 1 || ps[0].Field != ".addr" {
+		t.Errorf("problems=%v", ps)
+	}
+}
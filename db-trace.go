@@ -0,0 +1,282 @@
+package glib
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/openzipkin/zipkin-go"
+
+	gtrace "github.com/carltd/glib/trace"
+)
+
+const (
+	// key of the context in *gorm.DB
+	dbContextKey = "glib:context"
+	// key of the alias in *gorm.DB, to trace DBExec
+	dbAliasKey = "glib:alias"
+	// keys of the trace in gorm scope
+	dbSpanKey  = "glib:span"
+	dbStartKey = "glib:start"
+)
+
+// upper bounds of the latency histogram buckets
+var latencyBuckets = []time.Duration{
+	time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+	50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+}
+
+// LatencyHistogram is a snapshot of the latencies
+type LatencyHistogram struct {
+	// upper bounds of the buckets, there is one more bucket for the larger ones
+	Buckets []time.Duration
+	// count of each bucket, not cumulative
+	Counts []uint64
+	Count  uint64
+	Errors uint64
+	Sum    time.Duration
+}
+
+type latencyRecorder struct {
+	mu sync.Mutex
+	h  LatencyHistogram
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{h: LatencyHistogram{
+		Buckets: latencyBuckets,
+		Counts:  make([]uint64, len(latencyBuckets)+1),
+	}}
+}
+
+func (r *latencyRecorder) observe(d time.Duration, failed bool) {
+	i := 0
+	for i < len(r.h.Buckets) && d > r.h.Buckets[i] {
+		i++
+	}
+	r.mu.Lock()
+	r.h.Counts[i]++
+	r.h.Count++
+	r.h.Sum += d
+	if failed {
+		r.h.Errors++
+	}
+	r.mu.Unlock()
+}
+
+func (r *latencyRecorder) snapshot() *LatencyHistogram {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.h
+	h.Counts = append([]uint64(nil), r.h.Counts...)
+	return &h
+}
+
+type dbMetricKey struct {
+	alias, op string
+}
+
+// DBWithContext returns the master of alias by the default app, see App.DBWithContext
+func DBWithContext(ctx context.Context, alias string) *gorm.DB {
+	return defaultApp.DBWithContext(ctx, alias)
+}
+
+// DBReadWithContext returns a replica of alias by the default app, see App.DBReadWithContext
+func DBReadWithContext(ctx context.Context, alias string) *gorm.DB {
+	return defaultApp.DBReadWithContext(ctx, alias)
+}
+
+// DBMetrics returns the latency histograms of the default app, see App.DBMetrics
+func DBMetrics() map[string]map[string]*LatencyHistogram {
+	return defaultApp.DBMetrics()
+}
+
+// DBWithContext is like DB, the operations of the returned db are traced as the children of the span in ctx
+func (a *App) DBWithContext(ctx context.Context, alias string) *gorm.DB {
+	return a.DB(alias).Set(dbContextKey, ctx)
+}
+
+// DBReadWithContext is like DBRead, the operations of the returned db are traced as the children of the span in ctx
+func (a *App) DBReadWithContext(ctx context.Context, alias string) *gorm.DB {
+	return a.DBRead(alias).Set(dbContextKey, ctx)
+}
+
+// DBMetrics returns the latency histograms by alias and operation(create, query, update, delete, row_query, exec),
+// the replicas are named like db1/replica0.
+func (a *App) DBMetrics() map[string]map[string]*LatencyHistogram {
+	m := make(map[string]map[string]*LatencyHistogram)
+	a.dbMetrics.Range(func(key, value interface{}) bool {
+		k := key.(dbMetricKey)
+		if m[k.alias] == nil {
+			m[k.alias] = make(map[string]*LatencyHistogram)
+		}
+		m[k.alias][k.op] = value.(*latencyRecorder).snapshot()
+		return true
+	})
+	return m
+}
+
+// DBExec runs db.Exec, it's traced and measured like the other operations of db,
+// which gorm doesn't run callbacks for.
+//
+//	err := glib.DBExec(glib.DBWithContext(ctx, "db1"), "UPDATE user SET score = score + ? WHERE id = ?", n, id).Error
+func DBExec(db *gorm.DB, sql string, values ...interface{}) *gorm.DB {
+	v, ok := db.Get(dbAliasKey)
+	if !ok {
+		return db.Exec(sql, values...)
+	}
+	t := v.(*dbTracer)
+	start := time.Now()
+	var sp zipkin.Span
+	if v, ok := db.Get(dbContextKey); ok {
+		ctx, _ := v.(context.Context)
+		sp, _ = gtrace.StartSpanFromContext(ctx, "db/"+t.alias+"/exec")
+	}
+	res := db.Exec(sql, values...)
+	t.a.dbTraceFinish(t.alias, "exec", start, sp, "", sql, res.RowsAffected, res.Error)
+	return res
+}
+
+// dbTracer is the alias of a db traced by the app
+type dbTracer struct {
+	a     *App
+	alias string
+}
+
+// traceDB registers the callbacks to trace and measure the operations of db,
+// gorm doesn't run callbacks for Exec, the returned db keeps the alias for DBExec.
+func (a *App) traceDB(alias string, db *gorm.DB) *gorm.DB {
+	cb := db.Callback()
+	cb.Create().Before("gorm:begin_transaction").Register("glib:trace_before", a.dbTraceBefore(alias, "create"))
+	cb.Create().After("gorm:commit_or_rollback_transaction").Register("glib:trace_after", a.dbTraceAfter(alias, "create"))
+	cb.Update().Before("gorm:assign_updating_attributes").Register("glib:trace_before", a.dbTraceBefore(alias, "update"))
+	cb.Update().After("gorm:commit_or_rollback_transaction").Register("glib:trace_after", a.dbTraceAfter(alias, "update"))
+	cb.Delete().Before("gorm:begin_transaction").Register("glib:trace_before", a.dbTraceBefore(alias, "delete"))
+	cb.Delete().After("gorm:commit_or_rollback_transaction").Register("glib:trace_after", a.dbTraceAfter(alias, "delete"))
+	cb.Query().Before("gorm:query").Register("glib:trace_before", a.dbTraceBefore(alias, "query"))
+	cb.Query().After("gorm:after_query").Register("glib:trace_after", a.dbTraceAfter(alias, "query"))
+	cb.RowQuery().Before("gorm:row_query").Register("glib:trace_before", a.dbTraceBefore(alias, "row_query"))
+	cb.RowQuery().After("gorm:row_query").Register("glib:trace_after", a.dbTraceAfter(alias, "row_query"))
+	return db.Set(dbAliasKey, &dbTracer{a: a, alias: alias})
+}
+
+func (a *App) dbTraceBefore(alias, op string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		scope.InstanceSet(dbStartKey, time.Now())
+		v, ok := scope.Get(dbContextKey)
+		if !ok {
+			return
+		}
+		ctx, _ := v.(context.Context)
+		if sp, _ := gtrace.StartSpanFromContext(ctx, "db/"+alias+"/"+op); sp != nil {
+			scope.InstanceSet(dbSpanKey, sp)
+		}
+	}
+}
+
+func (a *App) dbTraceAfter(alias, op string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		v, ok := scope.InstanceGet(dbStartKey)
+		if !ok {
+			return
+		}
+		var sp zipkin.Span
+		if s, ok := scope.InstanceGet(dbSpanKey); ok {
+			sp = s.(zipkin.Span)
+		}
+		a.dbTraceFinish(alias, op, v.(time.Time), sp, scope.TableName(), scope.SQL, scope.DB().RowsAffected, dbTraceError(scope))
+	}
+}
+
+// dbTraceFinish measures the operation, and finishes its span if it's not nil
+func (a *App) dbTraceFinish(alias, op string, start time.Time, sp zipkin.Span, table, sql string, rows int64, err error) {
+	if err == gorm.ErrRecordNotFound {
+		err = nil
+	}
+	key := dbMetricKey{alias, op}
+	r, ok := a.dbMetrics.Load(key)
+	if !ok {
+		r, _ = a.dbMetrics.LoadOrStore(key, newLatencyRecorder())
+	}
+	r.(*latencyRecorder).observe(time.Since(start), err != nil)
+
+	if sp == nil {
+		return
+	}
+	sp.Tag("db.alias", alias)
+	if table != "" {
+		sp.Tag("db.table", table)
+	}
+	sp.Tag("db.statement", dbStatement(sql))
+	sp.Tag("db.rows", strconv.FormatInt(rows, 10))
+	if err != nil {
+		zipkin.TagError.Set(sp, err.Error())
+	}
+	sp.Finish()
+}
+
+// dbStatement replaces the literals of sql with ?, the values of Raw/Exec may be written in the sql
+// instead of placeholders. the quoted identifiers(`name`, "name") are kept.
+func dbStatement(sql string) string {
+	var b strings.Builder
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			// skip the escaped quotes: '' or \'
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] == '\\' {
+					j++
+				} else if sql[j] == '\'' {
+					if j+1 < len(sql) && sql[j+1] == '\'' {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteByte('?')
+			i = j
+		case c == '`' || c == '"':
+			j := strings.IndexByte(sql[i+1:], c)
+			if j < 0 {
+				b.WriteString(sql[i:])
+				return b.String()
+			}
+			b.WriteString(sql[i : i+j+2])
+			i += j + 1
+		case c >= '0' && c <= '9' && (i == 0 || !isSQLIdent(sql[i-1])):
+			j := i
+			for j < len(sql) && (isSQLIdent(sql[j]) || sql[j] == '.') {
+				j++
+			}
+			b.WriteByte('?')
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isSQLIdent reports whether c is a char of identifiers, $ is for the placeholders of postgres
+func isSQLIdent(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// dbTraceError returns the error of the operation
+func dbTraceError(scope *gorm.Scope) error {
+	err := scope.DB().Error
+	// the error of Rows is not in the db
+	if v, ok := scope.InstanceGet("row_query_result"); ok && err == nil {
+		if r, ok := v.(*gorm.RowsQueryResult); ok {
+			err = r.Error
+		}
+	}
+	return err
+}
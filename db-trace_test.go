@@ -0,0 +1,112 @@
+package glib
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+
+	gtrace "github.com/carltd/glib/trace"
+)
+
+func TestApp_DBMetrics(t *testing.T) {
+	a := newApp()
+	defer a.Close()
+	err := a.runDBManger(&dbConfig{Enable: true, Alias: "db1", Driver: "sqlite3", Dsn: "file:metrics?mode=memory", MaxIdle: 1, MaxOpen: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := a.DBWithContext(context.Background(), "db1")
+	if err = db.AutoMigrate(&txItem{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	db.Create(&txItem{ID: 1, Name: "a"})
+	db.Model(&txItem{ID: 1}).Update("name", "b")
+	var items []*txItem
+	db.Find(&items)
+	db.Raw("SELECT * FROM no_such_table").Rows()
+	db.Delete(&txItem{ID: 1})
+
+	m := a.DBMetrics()["db1"]
+	for _, op := range []string{"create", "update", "query", "row_query", "delete"} {
+		h := m[op]
+		if h == nil || h.Count == 0 {
+			t.Errorf("%s is not measured", op)
+			continue
+		}
+		var n uint64
+		for _, c := range h.Counts {
+			n += c
+		}
+		if n != h.Count || len(h.Counts) != len(h.Buckets)+1 {
+			t.Errorf("%s %+v", op, h)
+		}
+	}
+	if m["row_query"] != nil && m["row_query"].Errors != 1 {
+		t.Errorf("row_query errors=%d", m["row_query"].Errors)
+	}
+}
+
+func TestApp_DBTrace(t *testing.T) {
+	rec := recorder.NewReporter()
+	if err := gtrace.InitTracerWithReporter(gtrace.TracerConfig{SrvName: "test"}, rec); err != nil {
+		t.Fatal(err)
+	}
+	tracer, err := zipkin.NewTracer(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := newApp()
+	defer a.Close()
+	err = a.runDBManger(&dbConfig{Enable: true, Alias: "db1", Driver: "sqlite3", Dsn: "file:trace?mode=memory", MaxIdle: 1, MaxOpen: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.DB("db1").AutoMigrate(&txItem{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	parent := tracer.StartSpan("parent")
+	db := a.DBWithContext(zipkin.NewContext(context.Background(), parent), "db1")
+	db.Create(&txItem{ID: 1, Name: "a"})
+	if err = DBExec(db, "UPDATE tx_item SET name = 'b' WHERE id = 1").Error; err != nil {
+		t.Fatal(err)
+	}
+	parent.Finish()
+
+	spans := make(map[string]bool)
+	for _, sp := range rec.Flush() {
+		if sp.ParentID == nil {
+			continue
+		}
+		if *sp.ParentID != parent.Context().ID || sp.TraceID != parent.Context().TraceID {
+			t.Errorf("%s is not a child of the parent", sp.Name)
+		}
+		spans[sp.Name] = true
+		if sp.Name == "db/db1/exec" && sp.Tags["db.statement"] != "UPDATE tx_item SET name = ? WHERE id = ?" {
+			t.Errorf("exec statement %q", sp.Tags["db.statement"])
+		}
+	}
+	if !spans["db/db1/create"] || !spans["db/db1/exec"] {
+		t.Errorf("spans %v", spans)
+	}
+	if h := a.DBMetrics()["db1"]["exec"]; h == nil || h.Count != 1 {
+		t.Errorf("exec is not measured")
+	}
+}
+
+func TestDBStatement(t *testing.T) {
+	for sql, want := range map[string]string{
+		"SELECT * FROM `user` WHERE id = ?":                       "SELECT * FROM `user` WHERE id = ?",
+		"UPDATE t1 SET name = 'it''s', v = 'a\\'b' WHERE id = 12": "UPDATE t1 SET name = ?, v = ? WHERE id = ?",
+		`SELECT "col1" FROM t WHERE x > -1.5 AND y = $1`:          `SELECT "col1" FROM t WHERE x > -? AND y = $1`,
+		"INSERT INTO t VALUES (0x1f, 'unclosed":                   "INSERT INTO t VALUES (?, ?",
+	} {
+		if got := dbStatement(sql); got != want {
+			t.Errorf("dbStatement(%q)=%q, want %q", sql, got, want)
+		}
+	}
+}
@@ -2,54 +2,247 @@ package glib
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"log"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/jinzhu/gorm"
-	_ "github.com/jinzhu/gorm/dialects/mysql"
+
+	_ "github.com/carltd/glib/dialects/mysql"
+	"github.com/carltd/glib/internal"
+	"github.com/carltd/glib/migrate"
+)
+
+// policies to select a replica for DBRead
+const (
+	DBPolicyRoundRobin         = "round-robin"
+	DBPolicyRandom             = "random"
+	DBPolicyLeastRecentFailure = "least-recent-failure"
 )
 
 // DBConfig is config for struct
 type dbConfig struct {
 	Enable  bool          `json:"enable"`
 	Debug   bool          `json:"debug"`
-	Alias   string        `json:"alias"`
-	Driver  string        `json:"driver"`
-	Dsn     string        `json:"dsn"`
-	TTL     time.Duration `json:"ttl"`
-	MaxIdle int           `json:"maxIdle"`
-	MaxOpen int           `json:"maxOpen"`
-}
+	Alias   string        `json:"alias" validate:"required,unique"`
+	Driver  string        `json:"driver" validate:"required"`
+	Dsn     string        `json:"dsn" validate:"required"`
+	TTL     time.Duration `json:"ttl" validate:"min=0"`
+	MaxIdle int           `json:"maxIdle" validate:"min=0"` // 0 is the default of database/sql, 2
+	MaxOpen int           `json:"maxOpen" validate:"min=0"` // 0 is unlimited
 
-var dbs sync.Map
+	// pool settings in seconds, 0 is unlimited
+	MaxLifetime time.Duration `json:"maxLifetime" validate:"min=0"`
+	MaxIdleTime time.Duration `json:"maxIdleTime" validate:"min=0"`
+
+	// connection settings in seconds, put into the dsn by driver, 0 is the default of the driver
+	DialTimeout      time.Duration `json:"dialTimeout" validate:"min=0"`
+	ReadTimeout      time.Duration `json:"readTimeout" validate:"min=0"`
+	WriteTimeout     time.Duration `json:"writeTimeout" validate:"min=0"`
+	StatementTimeout time.Duration `json:"statementTimeout" validate:"min=0"`
+
+	// dsn of the read-only replicas, the Dsn is the master
+	Replicas []string `json:"replicas"`
+	// how to select a replica, default is round-robin
+	Policy string `json:"policy" validate:"omitempty,oneof=round-robin random least-recent-failure"`
+}
 
-// DB will return a instance of `xorm.EngineGroup`, panic if it's not exists
+// DB will return the master of alias, panic if it's not exists
 func DB(alias string) *gorm.DB {
-	eg, ok := dbs.Load(alias)
+	return defaultApp.DB(alias)
+}
+
+// LookupDB is like DB, but returns *ErrAliasNotConfigured if it's not exists
+func LookupDB(alias string) (*gorm.DB, error) {
+	return defaultApp.LookupDB(alias)
+}
+
+// DBWrite is the same as DB, makes the intention clear when used with DBRead
+func DBWrite(alias string) *gorm.DB {
+	return defaultApp.DBWrite(alias)
+}
+
+// LookupDBWrite is like DBWrite, but returns *ErrAliasNotConfigured if it's not exists
+func LookupDBWrite(alias string) (*gorm.DB, error) {
+	return defaultApp.LookupDBWrite(alias)
+}
+
+// DBRead will return a healthy replica of alias selected by the policy,
+// the master if there is not any, panic if it's not exists
+func DBRead(alias string) *gorm.DB {
+	return defaultApp.DBRead(alias)
+}
+
+// LookupDBRead is like DBRead, but returns *ErrAliasNotConfigured if it's not exists
+func LookupDBRead(alias string) (*gorm.DB, error) {
+	return defaultApp.LookupDBRead(alias)
+}
+
+// DB - see the package function DB
+func (a *App) DB(alias string) *gorm.DB {
+	db, err := a.LookupDB(alias)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// LookupDB - see the package function LookupDB
+func (a *App) LookupDB(alias string) (*gorm.DB, error) {
+	g, err := a.lookupDBGroup(alias)
+	if err != nil {
+		return nil, err
+	}
+	return g.master, nil
+}
+
+// DBWrite - see the package function DBWrite
+func (a *App) DBWrite(alias string) *gorm.DB {
+	return a.DB(alias)
+}
+
+// LookupDBWrite - see the package function LookupDBWrite
+func (a *App) LookupDBWrite(alias string) (*gorm.DB, error) {
+	return a.LookupDB(alias)
+}
+
+// DBRead - see the package function DBRead
+func (a *App) DBRead(alias string) *gorm.DB {
+	db, err := a.LookupDBRead(alias)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// LookupDBRead - see the package function LookupDBRead
+func (a *App) LookupDBRead(alias string) (*gorm.DB, error) {
+	g, err := a.lookupDBGroup(alias)
+	if err != nil {
+		return nil, err
+	}
+	return g.read(), nil
+}
+
+func (a *App) lookupDBGroup(alias string) (*dbGroup, error) {
+	eg, ok := a.dbs.Load(alias)
 	if !ok {
-		panic(fmt.Errorf("glib: db[%s] not configed", alias))
+		return nil, &ErrAliasNotConfigured{Kind: KindDB, Alias: alias}
+	}
+	return eg.(*dbGroup), nil
+}
+
+// dbGroup is a master with its replicas
+type dbGroup struct {
+	master   *gorm.DB
+	replicas []*gorm.DB
+	balancer *Balancer
+
+	mu sync.Mutex
+	// index of the replicas removed by failed health checks
+	down map[int]bool
+}
+
+func newDBGroup(master *gorm.DB, replicas []*gorm.DB, policy string) *dbGroup {
+	strategy := BalanceRoundRobin
+	switch policy {
+	case DBPolicyRandom:
+		strategy = BalanceRandom
+	case DBPolicyLeastRecentFailure:
+		strategy = BalanceLeastRecentFailure
+	}
+	g := &dbGroup{
+		master:   master,
+		replicas: replicas,
+		balancer: NewBalancer(strategy),
+		down:     make(map[int]bool),
+	}
+	g.balancer.Update(g.healthyReplicas())
+	return g
+}
+
+// read returns a healthy replica, the master if there is not any
+func (g *dbGroup) read() *gorm.DB {
+	inst, err := g.balancer.Pick()
+	if err != nil {
+		return g.master
+	}
+	i, _ := strconv.Atoi(inst.ID)
+	return g.replicas[i]
+}
+
+func (g *dbGroup) healthyReplicas() []*Instance {
+	instances := make([]*Instance, 0, len(g.replicas))
+	for i := range g.replicas {
+		if !g.down[i] {
+			instances = append(instances, &Instance{ID: strconv.Itoa(i)})
+		}
+	}
+	return instances
+}
+
+// markReplica removes the replica from selection if err is not nil, or brings it back
+func (g *dbGroup) markReplica(i int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.down[i] == (err != nil) {
+		return
 	}
-	return eg.(*gorm.DB)
+	if err != nil {
+		g.down[i] = true
+		g.balancer.Report(&Instance{ID: strconv.Itoa(i)}, err)
+	} else {
+		delete(g.down, i)
+	}
+	g.balancer.Update(g.healthyReplicas())
+}
+
+// PingContext checks the master
+func (g *dbGroup) PingContext(ctx context.Context) error {
+	return g.master.DB().PingContext(ctx)
+}
+
+// Close closes the master and replicas, returns the first error
+func (g *dbGroup) Close() error {
+	err := g.master.Close()
+	for _, r := range g.replicas {
+		if e := r.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// a replica of dbGroup to check health
+type dbReplica struct {
+	g *dbGroup
+	i int
+}
+
+func (r *dbReplica) PingContext(ctx context.Context) error {
+	err := r.g.replicas[r.i].DB().PingContext(ctx)
+	r.g.markReplica(r.i, err)
+	return err
 }
 
-func runDBManger(ctx context.Context, opts ...*dbConfig) error {
+// alias of the replica in Health, eg: db1/replica0
+func dbReplicaAlias(alias string, i int) string {
+	return alias + "/replica" + strconv.Itoa(i)
+}
+
+func (a *App) runDBManger(opts ...*dbConfig) error {
 	for _, opt := range opts {
 		if opt.Enable {
-			db, err := gorm.Open(opt.Driver, opt.Dsn)
+			g, err := a.openDBGroup(opt)
 			if err != nil {
-				return fmt.Errorf("glib: db (%s) %v", opt.Alias, err)
-			}
-			db.DB().SetMaxIdleConns(opt.MaxIdle)
-			db.DB().SetMaxOpenConns(opt.MaxOpen)
-			db.LogMode(opt.Debug)
-			db.SingularTable(true)
-			dbs.Store(opt.Alias, db)
-			if err = db.DB().Ping(); err != nil {
-				return fmt.Errorf("[db] %s resource err:%s", opt.Alias, err)
+				return err
 			}
+			a.dbs.Store(opt.Alias, g)
 			if opt.TTL > 0 {
-				go dbHealthCheck(ctx, opt.TTL, db)
+				go a.dbHealthCheck(opt.TTL, opt.Alias, g)
 			}
 		}
 	}
@@ -57,22 +250,124 @@ func runDBManger(ctx context.Context, opts ...*dbConfig) error {
 	return nil
 }
 
-// check database health, just ping it.
-func dbHealthCheck(ctx context.Context, ttl time.Duration, db *gorm.DB) {
+// migrate applies the pending migrations of every db alias
+func (a *App) migrate() error {
+	for _, alias := range syncMapKeys(&a.dbs) {
+		db, err := a.LookupDB(alias)
+		if err != nil {
+			continue
+		}
+		done, err := migrate.Up(db, alias)
+		if err != nil {
+			return fmt.Errorf("glib: db (%s) %v", alias, err)
+		}
+		if len(done) > 0 {
+			log.Printf("glib: db (%s) migrated %v", alias, done)
+		}
+	}
+	return nil
+}
+
+// openDBGroup opens the master and replicas of opt, their operations are traced and measured
+func (a *App) openDBGroup(opt *dbConfig) (*dbGroup, error) {
+	master, err := openDB(opt, opt.Dsn)
+	if err != nil {
+		return nil, err
+	}
+	master = a.traceDB(opt.Alias, master)
+	replicas := make([]*gorm.DB, 0, len(opt.Replicas))
+	for i, dsn := range opt.Replicas {
+		r := *opt
+		r.Alias = dbReplicaAlias(opt.Alias, i)
+		db, err := openDB(&r, dsn)
+		if err != nil {
+			_ = newDBGroup(master, replicas, opt.Policy).Close()
+			return nil, err
+		}
+		db = a.traceDB(r.Alias, db)
+		replicas = append(replicas, db)
+	}
+	return newDBGroup(master, replicas, opt.Policy), nil
+}
+
+func openDB(opt *dbConfig, dsn string) (*gorm.DB, error) {
+	dsn, err := dbDSN(opt, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("glib: db (%s) %v", opt.Alias, err)
+	}
+	db, err := gorm.Open(opt.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("glib: db (%s) %v", opt.Alias, err)
+	}
+	if opt.MaxIdle > 0 {
+		db.DB().SetMaxIdleConns(opt.MaxIdle)
+	}
+	db.DB().SetMaxOpenConns(opt.MaxOpen)
+	db.DB().SetConnMaxLifetime(opt.MaxLifetime * time.Second)
+	// requires go 1.15
+	db.DB().SetConnMaxIdleTime(opt.MaxIdleTime * time.Second)
+	db.LogMode(opt.Debug)
+	db.SingularTable(true)
+	if err = db.DB().Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("[db] %s resource err:%s", opt.Alias, err)
+	}
+	return db, nil
+}
+
+// dbDSN puts the connection settings of opt into dsn by the dialect of driver
+func dbDSN(opt *dbConfig, dsn string) (string, error) {
+	format, ok := internal.DBDialect(opt.Driver)
+	if !ok {
+		return "", fmt.Errorf("unknown driver %q (forgotten import?)", opt.Driver)
+	}
+	return format(dsn, &internal.DBSettings{
+		DialTimeout:      opt.DialTimeout * time.Second,
+		ReadTimeout:      opt.ReadTimeout * time.Second,
+		WriteTimeout:     opt.WriteTimeout * time.Second,
+		StatementTimeout: opt.StatementTimeout * time.Second,
+	})
+}
+
+// DBStats returns the pool statistics of the dbs by alias, see App.DBStats
+func DBStats() map[string]sql.DBStats {
+	return defaultApp.DBStats()
+}
+
+// DBStats returns the pool statistics of every db and replica by alias, eg: db1, db1/replica0
+func (a *App) DBStats() map[string]sql.DBStats {
+	stats := make(map[string]sql.DBStats)
+	a.dbs.Range(func(key, value interface{}) bool {
+		alias, g := key.(string), value.(*dbGroup)
+		stats[alias] = g.master.DB().Stats()
+		for i, r := range g.replicas {
+			stats[dbReplicaAlias(alias, i)] = r.DB().Stats()
+		}
+		return true
+	})
+	return stats
+}
+
+// check the health of database and its replicas, the result is recorded for Health,
+// the replicas failed are not selected by DBRead until they recovered.
+// it stops when the db of alias is replaced or removed.
+func (a *App) dbHealthCheck(ttl time.Duration, alias string, g *dbGroup) {
 	t := time.NewTicker(ttl * time.Second)
+	defer t.Stop()
 	for {
 		select {
-		case <-ctx.Done():
+		case <-a.ctx.Done():
 			return
 		case <-t.C:
-			db.DB().Ping()
+			if cur, ok := a.dbs.Load(alias); !ok || cur != g {
+				return
+			}
+			ctx, cancel := context.WithTimeout(a.ctx, ttl*time.Second)
+			a.checkHealth(ctx, KindDB, alias, g)
+			for i := range g.replicas {
+				a.checkHealth(ctx, KindDB, dbReplicaAlias(alias, i), &dbReplica{g, i})
+			}
+			cancel()
 		}
 	}
 }
-
-func closeDb() {
-	dbs.Range(func(key, value interface{}) bool {
-		err := value.(*gorm.DB).Close()
-		return err != nil
-	})
-}
@@ -0,0 +1,134 @@
+package glib
+
+import (
+	"context"
+	"testing"
+
+	_ "github.com/carltd/glib/dialects/mssql"
+	_ "github.com/carltd/glib/dialects/postgres"
+	_ "github.com/carltd/glib/dialects/sqlite"
+)
+
+func TestApp_DBReplicas(t *testing.T) {
+	a := newApp()
+	defer a.Close()
+	err := a.runDBManger(&dbConfig{
+		Enable:   true,
+		Alias:    "db1",
+		Driver:   "sqlite3",
+		Dsn:      "file:master?mode=memory",
+		MaxIdle:  1,
+		MaxOpen:  1,
+		Replicas: []string{"file:replica0?mode=memory", "file:replica1?mode=memory"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, _ := a.lookupDBGroup("db1")
+	if a.DB("db1") != g.master || a.DBWrite("db1") != g.master {
+		t.Error("DB and DBWrite should return the master")
+	}
+	seen := map[interface{}]int{}
+	for i := 0; i < 4; i++ {
+		seen[a.DBRead("db1")]++
+	}
+	if seen[g.replicas[0]] != 2 || seen[g.replicas[1]] != 2 {
+		t.Errorf("replicas are not selected in turn: %v", seen)
+	}
+
+	if stats := a.DBStats(); len(stats) != 3 || stats["db1/replica1"].MaxOpenConnections != 1 {
+		t.Errorf("stats=%v", stats)
+	}
+
+	// the replica failed is removed by health check
+	_ = g.replicas[0].Close()
+	for _, s := range a.Health(context.Background()) {
+		if s.Alias == "db1/replica0" && s.Status != HealthDown {
+			t.Errorf("replica0 %+v", s)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if a.DBRead("db1") != g.replicas[1] {
+			t.Fatal("the failed replica is still selected")
+		}
+	}
+
+	// the master is used if all replicas are down
+	_ = g.replicas[1].Close()
+	a.Health(context.Background())
+	if a.DBRead("db1") != g.master {
+		t.Error("DBRead should fall back to the master")
+	}
+}
+
+func TestDBDSN(t *testing.T) {
+	cases := []struct{ driver, dsn, want string }{
+		{"mysql", "root:@tcp(127.0.0.1:3306)/test?readTimeout=5s",
+			"root@tcp(127.0.0.1:3306)/test?readTimeout=5s&timeout=3s&max_execution_time=2000"},
+		{"postgres", "postgres://root@127.0.0.1/test?sslmode=disable",
+			"postgres://root@127.0.0.1/test?connect_timeout=3&sslmode=disable&statement_timeout=2000"},
+		{"postgres", "host=127.0.0.1 dbname=test connect_timeout=1",
+			"host=127.0.0.1 dbname=test connect_timeout=1 statement_timeout=2000"},
+	}
+	for _, c := range cases {
+		opt := &dbConfig{Driver: c.driver, DialTimeout: 3, StatementTimeout: 2}
+		if c.driver == "mysql" {
+			opt.ReadTimeout = 10
+		}
+		got, err := dbDSN(opt, c.dsn)
+		if err != nil || got != c.want {
+			t.Errorf("%s dsn=%s err=%v, want %s", c.driver, got, err, c.want)
+		}
+	}
+
+	// the settings the driver can't honor are rejected
+	for _, opt := range []*dbConfig{
+		{Driver: "postgres", ReadTimeout: 10},
+		{Driver: "postgres", WriteTimeout: 10},
+		{Driver: "sqlite3", DialTimeout: 3},
+		{Driver: "sqlite3", StatementTimeout: 2},
+	} {
+		if _, err := dbDSN(opt, "file:test"); err == nil {
+			t.Errorf("%s with %+v should fail", opt.Driver, opt)
+		}
+	}
+	if got, err := dbDSN(&dbConfig{Driver: "sqlite3"}, "file:test?mode=memory"); err != nil || got != "file:test?mode=memory" {
+		t.Errorf("sqlite3 dsn=%s err=%v", got, err)
+	}
+
+	mssql := &dbConfig{Driver: "mssql", DialTimeout: 3}
+	if got, err := dbDSN(mssql, "server=127.0.0.1;database=test;"); err != nil || got != "server=127.0.0.1;database=test;dial timeout=3" {
+		t.Errorf("mssql dsn=%s err=%v", got, err)
+	}
+	mssql.ReadTimeout = 10
+	if _, err := dbDSN(mssql, "server=127.0.0.1"); err == nil {
+		t.Error("mssql with read timeout should fail")
+	}
+
+	if _, err := dbDSN(&dbConfig{Driver: "oracle"}, ""); err == nil {
+		t.Error("unknown driver should fail")
+	}
+}
+
+func TestLoadConfigs_DBDSN(t *testing.T) {
+	cc, err := newConfigCenter(WithConfigSource(NewFileSource("testdata/conf")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.update(0, map[string][]byte{
+		glibConfigEnablesKey: []byte(`{"db": true}`),
+		glibConfigDb: []byte(`[
+			{"enable":true,"alias":"db1","driver":"postgres","dsn":"host=127.0.0.1","readTimeout":5},
+			{"enable":true,"alias":"db2","driver":"postgres","dsn":"host=127.0.0.1","replicas":["host=127.0.0.2","postgres://%zz"]}
+		]`),
+	})
+	_, err = loadConfigs(cc, &featureEnabledOptions{})
+	problems, ok := err.(ConfigError)
+	if !ok || len(problems) != 2 {
+		t.Fatalf("err=%v, want 2 problems", err)
+	}
+	if problems[0].Field != "[0]" || problems[1].Field != "[1].replicas[1]" {
+		t.Errorf("problems=%v", problems)
+	}
+}
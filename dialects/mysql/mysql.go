@@ -0,0 +1,40 @@
+package mysql // import "github.com/carltd/glib/dialects/mysql"
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jinzhu/gorm/dialects/mysql"
+
+	"github.com/carltd/glib/internal"
+)
+
+// FormatDSN puts the timeouts into the params of dsn,
+// the statement timeout is `max_execution_time` which requires mysql 5.7.8+
+func FormatDSN(dsn string, s *internal.DBSettings) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = s.DialTimeout
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = s.ReadTimeout
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = s.WriteTimeout
+	}
+	if _, ok := cfg.Params["max_execution_time"]; !ok && s.StatementTimeout > 0 {
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]string)
+		}
+		cfg.Params["max_execution_time"] = strconv.FormatInt(int64(s.StatementTimeout/time.Millisecond), 10)
+	}
+	return cfg.FormatDSN(), nil
+}
+
+func init() {
+	internal.RegisterDBDialect("mysql", FormatDSN)
+}
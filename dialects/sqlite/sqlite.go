@@ -0,0 +1,23 @@
+// Package sqlite makes sqlite3 usable in glib-db, it requires cgo.
+// the dsn is a file name or uri, eg: `file:test.db`, `file:test?mode=memory`.
+package sqlite // import "github.com/carltd/glib/dialects/sqlite"
+
+import (
+	"errors"
+
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+
+	"github.com/carltd/glib/internal"
+)
+
+// FormatDSN returns dsn as is, sqlite has no network timeouts, it fails if any of them is set.
+func FormatDSN(dsn string, s *internal.DBSettings) (string, error) {
+	if s.DialTimeout > 0 || s.ReadTimeout > 0 || s.WriteTimeout > 0 || s.StatementTimeout > 0 {
+		return "", errors.New("sqlite3 doesn't support dialTimeout, readTimeout, writeTimeout and statementTimeout")
+	}
+	return dsn, nil
+}
+
+func init() {
+	internal.RegisterDBDialect("sqlite3", FormatDSN)
+}
@@ -2,6 +2,9 @@ package glib
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+
 	"github.com/carltd/glib/internal"
 	gtrace "github.com/carltd/glib/trace"
 )
@@ -26,114 +29,159 @@ const (
 	glibConfigRedis      = "glib-redis"
 )
 
-var (
-	ctx, stop         = context.WithCancel(context.Background())
-	defEnabledOptions = &featureEnabledOptions{false, false, false, false, false, false}
-	confCenter        *configCenter
-)
-
-// Init enabled features
-func Init(opts ...option) error {
-
-	var err error
+// the app used by package functions
+var defaultApp = newApp()
+
+// configs of the enabled features
+type glibConfigs struct {
+	db     []*dbConfig
+	cache  []*internal.CacheConfig
+	mgo    []*mgoConfig
+	broker []*brokerConfig
+	redis  []*redisConfig
+	tracer *gtrace.TracerConfig
+}
 
-	confCenter, err = newConfigCenter(opts...)
-	if err != nil {
-		return err
+// loadConfigs loads and validates the keys of enabled features,
+// all problems will be returned in one ConfigError.
+func loadConfigs(cc *configCenter, enabled *featureEnabledOptions) (*glibConfigs, error) {
+	var (
+		problems ConfigError
+		cfg      = &glibConfigs{}
+		domain   = cc.Options().ServiceDomain
+	)
+
+	load := func(key string, v interface{}) bool {
+		fullKey := domain + "/" + key
+		buf := cc.Raw(key).Bytes()
+		if len(buf) == 0 {
+			problems = append(problems, &ConfigProblem{Key: fullKey, Msg: "not found"})
+			return false
+		}
+		if err := json.Unmarshal(buf, v); err != nil {
+			problems = append(problems, &ConfigProblem{Key: fullKey, Msg: "invalid json: " + err.Error()})
+			return false
+		}
+		problems = append(problems, validateConfig(fullKey, v)...)
+		return true
 	}
 
-	if err = confCenter.Load(glibConfigEnablesKey, defEnabledOptions); err != nil {
-		return release(err)
+	if !load(glibConfigEnablesKey, enabled) {
+		return nil, problems
 	}
 
-	// init database
-	if defEnabledOptions.Db {
-		dbConfig := make([]*dbConfig, 0)
-		if err = confCenter.Load(glibConfigDb, &dbConfig); err != nil {
-			return release(err)
-		}
-
-		if err = runDBManger(ctx, dbConfig...); err != nil {
-			return release(err)
+	if enabled.Db && load(glibConfigDb, &cfg.db) {
+		for i, c := range cfg.db {
+			if _, ok := internal.DBDialect(c.Driver); c.Enable && c.Driver != "" && !ok {
+				problems = append(problems, &ConfigProblem{
+					Key: domain + "/" + glibConfigDb, Field: fmt.Sprintf("[%d].driver", i),
+					Msg: fmt.Sprintf("unknown driver %q (forgotten import?)", c.Driver),
+				})
+			} else if _, err := dbDSN(c, c.Dsn); c.Enable && c.Driver != "" && err != nil {
+				problems = append(problems, &ConfigProblem{
+					Key: domain + "/" + glibConfigDb, Field: fmt.Sprintf("[%d]", i),
+					Msg: err.Error(),
+				})
+			} else if c.Enable && c.Driver != "" {
+				// the settings are checked by the master, only the dsn of replicas can fail
+				for j, dsn := range c.Replicas {
+					if _, err := dbDSN(c, dsn); err != nil {
+						problems = append(problems, &ConfigProblem{
+							Key: domain + "/" + glibConfigDb, Field: fmt.Sprintf("[%d].replicas[%d]", i, j),
+							Msg: err.Error(),
+						})
+					}
+				}
+			}
 		}
 	}
 
-	// init cache
-	if defEnabledOptions.Cache {
-		cacheConfig := make([]*internal.CacheConfig, 0)
-		if err = confCenter.Load(glibConfigCache, &cacheConfig); err != nil {
-			return release(err)
-		}
-
-		if err = runCacheManger(ctx, cacheConfig...); err != nil {
-			return release(err)
+	if enabled.Cache && load(glibConfigCache, &cfg.cache) {
+		for i, c := range cfg.cache {
+			if _, ok := internal.CacheDriver(c.Driver); c.Enable && !ok {
+				problems = append(problems, &ConfigProblem{
+					Key: domain + "/" + glibConfigCache, Field: fmt.Sprintf("[%d].driver", i),
+					Msg: fmt.Sprintf("unknown driver %q (forgotten import?)", c.Driver),
+				})
+			}
 		}
 	}
 
-	// init mongodb
-	if defEnabledOptions.Mgo {
-		mgoConfig := make([]*mgoConfig, 0)
-		if err = confCenter.Load(glibConfigMgo, &mgoConfig); err != nil {
-			return release(err)
-		}
+	if enabled.Mgo {
+		load(glibConfigMgo, &cfg.mgo)
+	}
 
-		if err = runMgoManager(mgoConfig...); err != nil {
-			return release(err)
+	if enabled.Broker && load(glibConfigBroker, &cfg.broker) {
+		for i, c := range cfg.broker {
+			if c.Enable && c.Driver != "" && !brokerDriverRegistered(c.Driver) {
+				problems = append(problems, &ConfigProblem{
+					Key: domain + "/" + glibConfigBroker, Field: fmt.Sprintf("[%d].driver", i),
+					Msg: fmt.Sprintf("unknown driver %q (forgotten import?)", c.Driver),
+				})
+			}
 		}
 	}
 
-	// init broker
-	if defEnabledOptions.Broker {
-		brokerConfig := make([]*brokerConfig, 0)
-		if err = confCenter.Load(glibConfigBroker, &brokerConfig); err != nil {
-			return release(err)
-		}
-		if err = runBrokerManager(brokerConfig...); err != nil {
-			return release(err)
-		}
+	if enabled.Redis {
+		load(glibConfigRedis, &cfg.redis)
 	}
 
-	if defEnabledOptions.Redis {
-		rdsConfig := make([]*redisConfig, 0)
-		if err = confCenter.Load(glibConfigRedis, &rdsConfig); err != nil {
-			return release(err)
-		}
-		if err = runRedisManger(ctx, rdsConfig...); err != nil {
-			return release(err)
-		}
+	if enabled.Tracer {
+		cfg.tracer = &gtrace.TracerConfig{}
+		load(glibConfigTracer, cfg.tracer)
 	}
 
-	// init tracer
-	if defEnabledOptions.Tracer {
-		tCfg := gtrace.TracerConfig{}
-		if err = confCenter.Load(glibConfigTracer, &tCfg); err != nil {
-			return release(err)
-		}
-		tCfg.SrvName = confCenter.Options().ServiceDomain
-		tCfg.HostPort = confCenter.Options().RunAt
-		if err = gtrace.InitTracer(tCfg); err != nil {
-			return release(err)
-		}
+	if len(problems) > 0 {
+		return nil, problems
 	}
+	return cfg, nil
+}
 
+// Init enabled features of the default app used by package functions,
+// the config of all enabled features will be validated before any connection
+// is opened, the problems are returned in a ConfigError.
+// the previous default app should be released by Destroy before Init again.
+func Init(opts ...option) error {
+	app, err := New(opts...)
+	if err != nil {
+		return err
+	}
+	// the repositories of NewMongoRepo belong to the default app, their aliases should be opened
+	ctx, cancel := context.WithTimeout(app.ctx, mongoIndexTimeout)
+	err = app.ensureMongoIndexes(ctx)
+	cancel()
+	if err != nil {
+		return app.release(err)
+	}
+	defaultApp = app
 	return nil
 }
 
-// get custom config Object
+// get custom config Object, keyPath can be a key or a key followed by the path
+// into its json value, eg: GetConfig("payment/limits.daily").Int(100)
 func GetConfig(keyPath string) ConfigObject {
-	return confCenter.Raw(keyPath)
+	return defaultApp.GetConfig(keyPath)
+}
+
+// ConfigOrigin - names of the config sources which have the keyPath,
+// from the lowest precedence to the highest, the value of the last one wins.
+func ConfigOrigin(keyPath string) []string {
+	return defaultApp.ConfigOrigin(keyPath)
 }
 
-func release(err error) error {
-	stop()
-	closeDb()
-	closeMgo()
-	closeBroker()
-	closeRedis()
-	return err
+// WatchConfig - call fn when the value of keyPath changed in the config center,
+// it should be called after Init.
+// example:
+//
+//	glib.WatchConfig("feature-flags", func(old, new glib.ConfigObject) {
+//		flags := new.StringMap(nil)
+//		// use it
+//	})
+func WatchConfig(keyPath string, fn ConfigWatcher) {
+	defaultApp.WatchConfig(keyPath, fn)
 }
 
-// Destroy - 释放glib管理资源
+// Destroy - 释放glib管理资源, see Shutdown
 func Destroy() error {
-	return release(nil)
+	return defaultApp.Close()
 }
@@ -1,6 +1,6 @@
 module github.com/carltd/glib
 
-go 1.12
+go 1.15
 
 require (
 	github.com/Shopify/sarama v1.22.1
@@ -9,9 +9,10 @@ require (
 	github.com/denisenkom/go-mssqldb v0.0.0-20190806190131-db2462fef53b // indirect
 	github.com/erikstmartin/go-testdb v0.0.0-20160219214506-8d10e4a1bae5 // indirect
 	github.com/garyburd/redigo v1.6.0
-	github.com/go-sql-driver/mysql v1.4.1 // indirect
-
+	github.com/go-sql-driver/mysql v1.4.1
+	github.com/go-stack/stack v1.8.1 // indirect
 	github.com/golang/protobuf v1.3.1
+	github.com/hashicorp/consul v1.4.2
 	github.com/jinzhu/gorm v1.9.1
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/jinzhu/now v1.0.1 // indirect
@@ -20,7 +21,9 @@ require (
 	github.com/mattn/go-sqlite3 v1.11.0 // indirect
 	github.com/micro/go-micro v1.0.0
 	github.com/openzipkin/zipkin-go v0.1.6
+	go.mongodb.org/mongo-driver v1.1.4
 	gopkg.in/mgo.v2 v2.0.0-20160818020120-3f83fa500528
+	gopkg.in/yaml.v2 v2.2.2
 )
 
 replace golang.org/x/text => github.com/golang/text v0.3.2
@@ -0,0 +1,191 @@
+package glib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// status of a resource
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+	// the resource can't be checked, eg: kafka publisher
+	HealthUnknown = "unknown"
+)
+
+// timeout of the readiness check served by HealthHandler
+const healthCheckTimeout = 5 * time.Second
+
+// HealthStatus is the result of the last check of a resource
+type HealthStatus struct {
+	Kind    string        `json:"kind"`
+	Alias   string        `json:"alias"`
+	Status  string        `json:"status"`
+	Latency time.Duration `json:"latency"`
+	// the last error is kept after the resource recovered
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+	CheckedAt   time.Time  `json:"checkedAt"`
+}
+
+// Health checks the resources managed by the default app, see App.Health
+func Health(ctx context.Context) []*HealthStatus {
+	return defaultApp.Health(ctx)
+}
+
+// HealthHandler serves the health of the default app, see App.HealthHandler
+func HealthHandler() http.Handler {
+	return defaultApp.HealthHandler()
+}
+
+// Health pings every db, mgo, cache, redis, publisher and consumer concurrently,
+// returns their status ordered by kind and alias.
+// resources not responded before ctx is done are down with ctx's error.
+func (a *App) Health(ctx context.Context) []*HealthStatus {
+	type item struct {
+		kind, alias string
+		res         interface{}
+	}
+	var items []item
+	for _, s := range a.healthStores() {
+		for _, alias := range syncMapKeys(s.store) {
+			res, ok := s.store.Load(alias)
+			if !ok {
+				continue
+			}
+			items = append(items, item{s.kind, alias, res})
+			if g, ok := res.(*dbGroup); ok {
+				for i := range g.replicas {
+					items = append(items, item{s.kind, dbReplicaAlias(alias, i), &dbReplica{g, i}})
+				}
+			}
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		result = make([]*HealthStatus, len(items))
+	)
+	for i, it := range items {
+		wg.Add(1)
+		go func(i int, it item) {
+			defer wg.Done()
+			result[i] = a.checkHealth(ctx, it.kind, it.alias, it.res)
+		}(i, it)
+	}
+	wg.Wait()
+	return result
+}
+
+// HealthHandler returns a handler for kubernetes probes, the response is json:
+//
+//	.../healthz - liveness, 200 until the app is shut down
+//	.../readyz  - readiness, checks all resources, 503 if any of them is down
+func (a *App) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var (
+			code = http.StatusOK
+			body = map[string]interface{}{"status": HealthUp}
+		)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/healthz"):
+			if a.ctx.Err() != nil {
+				code, body["status"] = http.StatusServiceUnavailable, HealthDown
+			}
+		case strings.HasSuffix(r.URL.Path, "/readyz"):
+			ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+			defer cancel()
+			resources := a.Health(ctx)
+			for _, s := range resources {
+				if s.Status == HealthDown {
+					code, body["status"] = http.StatusServiceUnavailable, HealthDown
+				}
+			}
+			if a.ctx.Err() != nil {
+				code, body["status"] = http.StatusServiceUnavailable, HealthDown
+			}
+			body["resources"] = resources
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+}
+
+type healthStore struct {
+	kind  string
+	store *sync.Map
+}
+
+func (a *App) healthStores() []healthStore {
+	return []healthStore{
+		{KindDB, &a.dbs},
+		{KindMgo, &a.mgos},
+		{KindCache, &a.caches},
+		{KindRedis, &a.rediss},
+		{KindPublisher, &a.publishers},
+		{KindConsumer, &a.consumers},
+	}
+}
+
+func (a *App) checkHealth(ctx context.Context, kind, alias string, res interface{}) *HealthStatus {
+	ping := pingerOf(res)
+	if ping == nil {
+		return a.recordHealth(kind, alias, HealthUnknown, 0, nil)
+	}
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- ping(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		return a.recordHealth(kind, alias, HealthDown, time.Since(start), err)
+	}
+	return a.recordHealth(kind, alias, HealthUp, time.Since(start), nil)
+}
+
+// pingerOf returns the function to check res, nil if it's not supported
+func pingerOf(res interface{}) func(ctx context.Context) error {
+	switch r := res.(type) {
+	case interface {
+		PingContext(ctx context.Context) error
+	}:
+		return r.PingContext
+	case interface{ Ping() error }:
+		return func(ctx context.Context) error { return r.Ping() }
+	}
+	return nil
+}
+
+// recordHealth saves the result of a check, the last error is inherited from the previous one
+func (a *App) recordHealth(kind, alias, status string, latency time.Duration, err error) *HealthStatus {
+	s := &HealthStatus{
+		Kind:      kind,
+		Alias:     alias,
+		Status:    status,
+		Latency:   latency,
+		CheckedAt: time.Now(),
+	}
+	key := kind + "[" + alias + "]"
+	if err != nil {
+		s.LastError, s.LastErrorAt = err.Error(), &s.CheckedAt
+	} else if prev, ok := a.health.Load(key); ok {
+		s.LastError, s.LastErrorAt = prev.(*HealthStatus).LastError, prev.(*HealthStatus).LastErrorAt
+	}
+	a.health.Store(key, s)
+	return s
+}
@@ -0,0 +1,85 @@
+package glib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakePinger struct {
+	err   error
+	delay time.Duration
+}
+
+func (p *fakePinger) Ping() error {
+	time.Sleep(p.delay)
+	return p.err
+}
+
+func TestApp_Health(t *testing.T) {
+	a := newApp()
+	a.caches.Store("c1", &fakePinger{})
+	a.rediss.Store("r1", &fakePinger{err: errors.New("connection refused")})
+	a.rediss.Store("r2", &fakePinger{delay: time.Second})
+	a.publishers.Store("p1", struct{}{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	got := a.Health(ctx)
+
+	want := []struct{ kind, alias, status, lastError string }{
+		{KindCache, "c1", HealthUp, ""},
+		{KindRedis, "r1", HealthDown, "connection refused"},
+		{KindRedis, "r2", HealthDown, context.DeadlineExceeded.Error()},
+		{KindPublisher, "p1", HealthUnknown, ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d status, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if s := got[i]; s.Kind != w.kind || s.Alias != w.alias || s.Status != w.status || s.LastError != w.lastError {
+			t.Errorf("[%d] got %+v, want %+v", i, s, w)
+		}
+	}
+
+	// the last error is kept after recovered
+	a.rediss.Store("r1", &fakePinger{})
+	for _, s := range a.Health(context.Background()) {
+		if s.Alias == "r1" && (s.Status != HealthUp || s.LastError != "connection refused") {
+			t.Errorf("got %+v", s)
+		}
+	}
+}
+
+func TestApp_HealthHandler(t *testing.T) {
+	a := newApp()
+	a.caches.Store("c1", &fakePinger{})
+	h := a.HealthHandler()
+
+	probe := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		return w.Code, body
+	}
+
+	if code, body := probe("/readyz"); code != http.StatusOK || body["status"] != HealthUp {
+		t.Errorf("readyz: %d %v", code, body)
+	}
+	a.caches.Store("c1", &fakePinger{err: errors.New("down")})
+	if code, body := probe("/readyz"); code != http.StatusServiceUnavailable || len(body["resources"].([]interface{})) != 1 {
+		t.Errorf("readyz: %d %v", code, body)
+	}
+	if code, _ := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("healthz: %d", code)
+	}
+	_ = a.Close()
+	if code, _ := probe("/healthz"); code != http.StatusServiceUnavailable {
+		t.Errorf("healthz after closed: %d", code)
+	}
+}
@@ -1,8 +1,8 @@
 package glib
 
-import "gopkg.in/mgo.v2/bson"
+import "go.mongodb.org/mongo-driver/bson/primitive"
 
 // NewId - return a global unique id
 func NewId() string {
-	return bson.NewObjectId().Hex()
+	return primitive.NewObjectID().Hex()
 }
@@ -30,10 +30,10 @@ type CacheCreator func(config *CacheConfig) Cacher
 
 type CacheConfig struct {
 	Enable bool          `json:"enable"`
-	Alias  string        `json:"alias"`
-	Driver string        `json:"driver"`
-	Dsn    string        `json:"dsn"`
-	TTL    time.Duration `json:"ttl"`
+	Alias  string        `json:"alias" validate:"required,unique"`
+	Driver string        `json:"driver" validate:"required"`
+	Dsn    string        `json:"dsn" validate:"required"`
+	TTL    time.Duration `json:"ttl" validate:"min=0"`
 }
 
 var (
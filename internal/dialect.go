@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// DBSettings are the connection settings of glib-db, which are put into the dsn by dialects
+type DBSettings struct {
+	DialTimeout      time.Duration
+	ReadTimeout      time.Duration
+	WriteTimeout     time.Duration
+	StatementTimeout time.Duration
+}
+
+// DSNFormatter puts the settings into dsn, the ones already in dsn should be kept.
+// it returns an error for the settings the driver can't honor, which fails the config validation
+type DSNFormatter func(dsn string, s *DBSettings) (string, error)
+
+var (
+	dbDialects sync.Map
+)
+
+// RegisterDBDialect makes the sql driver usable in glib-db, the gorm dialect of it should be imported too
+func RegisterDBDialect(driverName string, f DSNFormatter) {
+	dbDialects.Store(driverName, f)
+}
+
+func DBDialect(driver string) (DSNFormatter, bool) {
+	d, ok := dbDialects.Load(driver)
+	if ok {
+		f, ok := d.(DSNFormatter)
+		return f, ok
+	}
+	return nil, false
+}
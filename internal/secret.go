@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// SecretPrefix is the prefix of an encrypted value,
+// the format is `enc:v1:` + base64(nonce + AES-GCM sealed data)
+const SecretPrefix = "enc:v1:"
+
+var (
+	ErrSecretKey   = errors.New("secret key must be 16, 24 or 32 bytes, in base64 or raw")
+	ErrSecretValue = errors.New("bad encrypted value")
+)
+
+// IsSecret reports whether s is an encrypted value
+func IsSecret(s string) bool {
+	return strings.HasPrefix(s, SecretPrefix)
+}
+
+// ParseSecretKey parses the AES key in base64 or raw bytes
+func ParseSecretKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && validSecretKey(key) {
+		return key, nil
+	}
+	if key := []byte(s); validSecretKey(key) {
+		return key, nil
+	}
+	return nil, ErrSecretKey
+}
+
+// NewSecretKey returns a random 32 bytes key
+func NewSecretKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncryptSecret encrypts plain by AES-GCM, returns the value with SecretPrefix
+func EncryptSecret(key, plain []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, plain, nil)
+	return SecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret decrypts the value encrypted by EncryptSecret
+func DecryptSecret(key []byte, value string) ([]byte, error) {
+	if !IsSecret(value) {
+		return nil, ErrSecretValue
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SecretPrefix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, ErrSecretValue
+	}
+	n := gcm.NonceSize()
+	return gcm.Open(nil, sealed[:n], sealed[n:], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if !validSecretKey(key) {
+		return nil, ErrSecretKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func validSecretKey(key []byte) bool {
+	switch len(key) {
+	case 16, 24, 32:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,169 @@
+package glib
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// prefix of the lock keys in consul, out of the service domain to keep them away from config
+	lockKeyPrefix = "glib-locks/"
+	// TTL of the lock session, it's renewed by consul api while the lock is held
+	lockSessionTTL = "15s"
+	// max time of a blocking query waiting for the lock, ctx is checked between the queries
+	lockWaitTime = 5 * time.Second
+)
+
+// DistLock is a distributed lock held by the app
+type DistLock struct {
+	key  string
+	lock *api.Lock
+	lost <-chan struct{}
+
+	app  *App
+	once sync.Once
+	err  error
+}
+
+// Lock acquires the lock of key by the default app, see App.Lock
+func Lock(key string) (*DistLock, error) {
+	return defaultApp.Lock(key)
+}
+
+// LockContext acquires the lock of key by the default app, see App.LockContext
+func LockContext(ctx context.Context, key string) (*DistLock, error) {
+	return defaultApp.LockContext(ctx, key)
+}
+
+// Elect runs the leader election of key by the default app, see App.Elect
+func Elect(key string, onLeader func(ctx context.Context), onFollower func()) error {
+	return defaultApp.Elect(key, onLeader, onFollower)
+}
+
+// Lock - see LockContext
+func (a *App) Lock(key string) (*DistLock, error) {
+	return a.LockContext(context.Background(), key)
+}
+
+// LockContext blocks until the lock of key in the ServiceDomain is acquired, ctx is done,
+// or the app is shut down. the lock is held by a consul session which is renewed in background,
+// it's released by Unlock or when the app is shut down.
+func (a *App) LockContext(ctx context.Context, key string) (*DistLock, error) {
+	client, err := a.conf.consulClient()
+	if err != nil {
+		return nil, fmt.Errorf("glib: lock %s: %v", key, err)
+	}
+	fullKey := lockKeyPrefix + a.conf.Options().ServiceDomain + "/" + key
+	lock, err := client.LockOpts(&api.LockOptions{
+		Key:          fullKey,
+		SessionName:  a.conf.Options().ServiceDomain + " lock " + key,
+		SessionTTL:   lockSessionTTL,
+		LockWaitTime: lockWaitTime,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("glib: lock %s: %v", key, err)
+	}
+
+	stop, acquired := make(chan struct{}), make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			close(stop)
+		case <-a.ctx.Done():
+			close(stop)
+		case <-acquired:
+		}
+	}()
+
+	lost, err := lock.Lock(stop)
+	close(acquired)
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("glib: lock %s: %v", key, err)
+	case lost == nil:
+		if err = ctx.Err(); err == nil {
+			err = context.Canceled
+		}
+		return nil, fmt.Errorf("glib: lock %s: %v", key, err)
+	}
+
+	l := &DistLock{key: fullKey, lock: lock, lost: lost, app: a}
+	a.locks.Store(l, struct{}{})
+	return l, nil
+}
+
+// Lost is closed when the lock is lost, eg: the session is invalidated
+func (l *DistLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock releases the lock, it's safe to call it more than once
+func (l *DistLock) Unlock() error {
+	l.once.Do(func() {
+		l.app.locks.Delete(l)
+		if err := l.lock.Unlock(); err != nil && err != api.ErrLockNotHeld {
+			l.err = err
+		}
+	})
+	return l.err
+}
+
+// Elect runs for the leader of key until the app is shut down.
+// onFollower is called when the app is not the leader, at the beginning or after the
+// leadership is lost. onLeader is called when elected, its ctx is canceled when the
+// leadership is lost or the app is shut down. both of them may be nil.
+func (a *App) Elect(key string, onLeader func(ctx context.Context), onFollower func()) error {
+	if _, err := a.conf.consulClient(); err != nil {
+		return fmt.Errorf("glib: elect %s: %v", key, err)
+	}
+
+	go func() {
+		if onFollower != nil {
+			onFollower()
+		}
+		for a.ctx.Err() == nil {
+			l, err := a.LockContext(a.ctx, key)
+			if err != nil {
+				if a.ctx.Err() == nil {
+					log.Printf("glib: elect %s err: %v", key, err)
+					select {
+					case <-a.ctx.Done():
+					case <-time.After(consulWatchRetryDelay):
+					}
+				}
+				continue
+			}
+
+			ctx, cancel := context.WithCancel(a.ctx)
+			if onLeader != nil {
+				go onLeader(ctx)
+			}
+			select {
+			case <-l.Lost():
+			case <-a.ctx.Done():
+			}
+			cancel()
+			_ = l.Unlock()
+			if a.ctx.Err() == nil && onFollower != nil {
+				onFollower()
+			}
+		}
+	}()
+	return nil
+}
+
+// closings of the locks held
+func (a *App) lockClosings() []*closing {
+	cs := make([]*closing, 0)
+	a.locks.Range(func(key, value interface{}) bool {
+		l := key.(*DistLock)
+		cs = append(cs, &closing{kind: KindLock, alias: l.key, close: l.Unlock})
+		return true
+	})
+	return cs
+}
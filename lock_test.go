@@ -0,0 +1,132 @@
+package glib
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// fakeKV implements the session and kv api used by api.Lock
+type fakeKV struct {
+	mu       sync.Mutex
+	index    uint64
+	sessions int
+	pairs    map[string]*api.KVPair
+}
+
+func (f *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q := r.URL.Query()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+
+	switch p := r.URL.Path; {
+	case p == "/v1/session/create":
+		f.sessions++
+		_ = json.NewEncoder(w).Encode(map[string]string{"ID": "s" + strconv.Itoa(f.sessions)})
+	case strings.HasPrefix(p, "/v1/session/renew/"):
+		_ = json.NewEncoder(w).Encode([]*api.SessionEntry{{ID: strings.TrimPrefix(p, "/v1/session/renew/"), TTL: lockSessionTTL}})
+	case strings.HasPrefix(p, "/v1/session/destroy/"):
+		_, _ = w.Write([]byte("true"))
+	case strings.HasPrefix(p, "/v1/kv/") && r.Method == http.MethodGet:
+		key := strings.TrimPrefix(p, "/v1/kv/")
+		if wait := q.Get("index"); wait != "" && wait == strconv.FormatUint(f.index, 10) {
+			// a short blocking query
+			f.mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			f.mu.Lock()
+		}
+		pair, ok := f.pairs[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]*api.KVPair{pair})
+	case strings.HasPrefix(p, "/v1/kv/") && r.Method == http.MethodPut:
+		key := strings.TrimPrefix(p, "/v1/kv/")
+		pair, ok := f.pairs[key]
+		if !ok {
+			pair = &api.KVPair{Key: key}
+			f.pairs[key] = pair
+		}
+		switch {
+		case q.Get("acquire") != "":
+			if pair.Session != "" && pair.Session != q.Get("acquire") {
+				_, _ = w.Write([]byte("false"))
+				return
+			}
+			pair.Session = q.Get("acquire")
+		case q.Get("release") != "":
+			if pair.Session == q.Get("release") {
+				pair.Session = ""
+			}
+		}
+		pair.Flags, _ = strconv.ParseUint(q.Get("flags"), 10, 64)
+		pair.Value, _ = ioutil.ReadAll(r.Body)
+		f.index++
+		_, _ = w.Write([]byte("true"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newLockTestApp(t *testing.T, addr string) *App {
+	a := newApp()
+	var err error
+	a.conf, err = newConfigCenter(
+		WithServiceDomain("com.carltd.srv.demo"),
+		WithConfigSource(NewEnvSource("GLIB_LOCK_")),
+		WithDiscoverAddr(addr),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestApp_Lock(t *testing.T) {
+	kv := &fakeKV{index: 1, pairs: make(map[string]*api.KVPair)}
+	srv := httptest.NewServer(kv)
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	a1, a2 := newLockTestApp(t, addr), newLockTestApp(t, addr)
+	defer a2.Close()
+
+	if _, err := a1.Lock("job"); err != nil {
+		t.Fatal(err)
+	}
+	kv.mu.Lock()
+	_, ok := kv.pairs["glib-locks/com.carltd.srv.demo/job"]
+	kv.mu.Unlock()
+	if !ok {
+		t.Fatal("lock key is not under glib-locks/<domain>")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := a2.LockContext(ctx, "job"); err == nil {
+		t.Fatal("the lock is held by a1")
+	}
+
+	// released when a1 is shut down
+	if err := a1.Close(); err != nil {
+		t.Fatal(err)
+	}
+	l, err := a2.Lock("job")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
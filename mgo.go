@@ -1,83 +1,285 @@
 package glib
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoopt "go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"gopkg.in/mgo.v2"
 )
 
 type mgoConfig struct {
 	Enable bool          `json:"enable"`
-	Alias  string        `json:"alias"`
-	Dsn    string        `json:"dsn"`
-	TTL    time.Duration `json:"ttl"`
+	Alias  string        `json:"alias" validate:"required,unique"`
+	Dsn    string        `json:"dsn" validate:"required"`
+	TTL    time.Duration `json:"ttl" validate:"min=0"` // timeout in seconds, 0 is the default of the drivers
+
+	// pool of the official driver, the ones in dsn take precedence, 0 is the default of the driver
+	MaxPoolSize uint64 `json:"maxPoolSize"`
+	MinPoolSize uint64 `json:"minPoolSize"`
+	// in seconds
+	MaxConnIdleTime        time.Duration `json:"maxConnIdleTime"`
+	ConnectTimeout         time.Duration `json:"connectTimeout"`
+	SocketTimeout          time.Duration `json:"socketTimeout"`
+	ServerSelectionTimeout time.Duration `json:"serverSelectionTimeout"`
+	// the commands slower than it are logged, 0 is not to log
+	SlowThresholdMs int64 `json:"slowThresholdMs" validate:"min=0"`
 }
 
-var (
-	mgos = map[string]*mgo.Session{}
-)
+// mgoClients are the clients of a mgo alias
+type mgoClients struct {
+	opt    *mgoConfig
+	client *mongo.Client
+
+	mu sync.Mutex
+	// dialed by the first MgoShareCopy/MgoShareClone, nil before it
+	legacy *mgo.Session
+}
+
+// legacySession dials the mgo.v2 session if it's not dialed, a failed dial is retried by the next call
+func (c *mgoClients) legacySession() (*mgo.Session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.legacy != nil {
+		return c.legacy, nil
+	}
+	var (
+		s   *mgo.Session
+		err error
+	)
+	if c.opt.TTL > 0 {
+		s, err = mgo.DialWithTimeout(c.opt.Dsn, c.opt.TTL*time.Second)
+	} else {
+		s, err = mgo.Dial(c.opt.Dsn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("glib: mgo[%s] create legacy session err:%s", c.opt.Alias, err)
+	}
+	if c.opt.TTL > 0 {
+		s.SetSyncTimeout(c.opt.TTL * time.Second)
+		s.SetSocketTimeout(c.opt.TTL * time.Second)
+	}
+	c.legacy = s
+	return s, nil
+}
+
+func (c *mgoClients) PingContext(ctx context.Context) error {
+	if err := c.client.Ping(ctx, readpref.Primary()); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	legacy := c.legacy
+	c.mu.Unlock()
+	if legacy != nil {
+		return legacy.Ping()
+	}
+	return nil
+}
+
+func (c *mgoClients) Close() error {
+	c.mu.Lock()
+	if c.legacy != nil {
+		c.legacy.Close()
+		c.legacy = nil
+	}
+	c.mu.Unlock()
+	return c.client.Disconnect(context.Background())
+}
 
-// MgoShareCopy  will return a copy instance of `*mgo.Session`, panic if it's not exists
+// Mongo returns the client of the official driver, panic if it's not exists.
+// it's safe for concurrent use, and should not be disconnected by the caller.
 // example:
+//
+//	var v bson.M
+//	err := glib.Mongo("something").Database("somedb").Collection("col").FindOne(ctx, bson.M{}).Decode(&v)
+func Mongo(alias string) *mongo.Client {
+	return defaultApp.Mongo(alias)
+}
+
+// LookupMongo is like Mongo, but returns *ErrAliasNotConfigured if it's not exists
+func LookupMongo(alias string) (*mongo.Client, error) {
+	return defaultApp.LookupMongo(alias)
+}
+
+// Mongo - see the package function Mongo
+func (a *App) Mongo(alias string) *mongo.Client {
+	c, err := a.LookupMongo(alias)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// LookupMongo - see the package function LookupMongo
+func (a *App) LookupMongo(alias string) (*mongo.Client, error) {
+	c, err := a.lookupMgoClients(alias)
+	if err != nil {
+		return nil, err
+	}
+	return c.client, nil
+}
+
+func (a *App) lookupMgoClients(alias string) (*mgoClients, error) {
+	eg, ok := a.mgos.Load(alias)
+	if !ok {
+		return nil, &ErrAliasNotConfigured{Kind: KindMgo, Alias: alias}
+	}
+	return eg.(*mgoClients), nil
+}
+
+func (a *App) lookupMgoLegacy(alias string) (*mgo.Session, error) {
+	c, err := a.lookupMgoClients(alias)
+	if err != nil {
+		return nil, err
+	}
+	return c.legacySession()
+}
+
+// MgoShareCopy  will return a copy instance of `*mgo.Session`, panic if it's not exists or fails to dial.
+// the session of alias is dialed by the first call, its operations are not traced.
+// Deprecated: mgo.v2 is unmaintained, use Mongo instead.
+// example:
+//
 //	var v = make([]interface{}, 0)
 //	s := glib.MgoShareCopy("something")
 //	defer s.Close()
 //	// use it
 //	err := s.DB("somedb").C("col").Find(&v)
 func MgoShareCopy(alias string) *mgo.Session {
+	return defaultApp.MgoShareCopy(alias)
+}
 
-	eg, ok := mgos[alias]
-	if !ok {
-		panic(fmt.Errorf("glib: mgo[%s] not configed", alias))
-	}
-	var v []interface{}
-
-	eg.Copy().DB("").C("col").Find(&v)
-	return eg.Copy()
+// LookupMgoCopy is like MgoShareCopy, but returns *ErrAliasNotConfigured if it's not exists,
+// or the error of dialing
+func LookupMgoCopy(alias string) (*mgo.Session, error) {
+	return defaultApp.LookupMgoCopy(alias)
 }
 
-// MgoShareClone will return a clone instance of `*mgo.Session`, panic if it's not exists
+// MgoShareClone will return a clone instance of `*mgo.Session`, panic if it's not exists or fails to dial.
+// the session of alias is dialed by the first call, its operations are not traced.
+// Deprecated: mgo.v2 is unmaintained, use Mongo instead.
 // example:
+//
 //	var v = make([]interface{}, 0)
 //	s := glib.MgoShareClone("something")
 //	defer s.Close()
 //	// use it
 //	err := s.DB("somedb").C("col").Find(&v)
 func MgoShareClone(alias string) *mgo.Session {
+	return defaultApp.MgoShareClone(alias)
+}
 
-	eg, ok := mgos[alias]
-	if !ok {
-		panic(fmt.Errorf("glib: mgo[%s] not configed", alias))
+// LookupMgoClone is like MgoShareClone, but returns *ErrAliasNotConfigured if it's not exists,
+// or the error of dialing
+func LookupMgoClone(alias string) (*mgo.Session, error) {
+	return defaultApp.LookupMgoClone(alias)
+}
+
+// MgoShareCopy - see the package function MgoShareCopy
+func (a *App) MgoShareCopy(alias string) *mgo.Session {
+	s, err := a.LookupMgoCopy(alias)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// LookupMgoCopy - see the package function LookupMgoCopy
+func (a *App) LookupMgoCopy(alias string) (*mgo.Session, error) {
+	s, err := a.lookupMgoLegacy(alias)
+	if err != nil {
+		return nil, err
 	}
+	return s.Copy(), nil
+}
 
-	return eg.Clone()
+// MgoShareClone - see the package function MgoShareClone
+func (a *App) MgoShareClone(alias string) *mgo.Session {
+	s, err := a.LookupMgoClone(alias)
+	if err != nil {
+		panic(err)
+	}
+	return s
 }
 
-func runMgoManager(opts ...*mgoConfig) error {
+// LookupMgoClone - see the package function LookupMgoClone
+func (a *App) LookupMgoClone(alias string) (*mgo.Session, error) {
+	s, err := a.lookupMgoLegacy(alias)
+	if err != nil {
+		return nil, err
+	}
+	return s.Clone(), nil
+}
+
+func (a *App) runMgoManager(opts ...*mgoConfig) error {
 	for _, opt := range opts {
 		if opt.Enable {
-			s, err := mgo.DialWithTimeout(opt.Dsn, opt.TTL*time.Second)
+			c, err := openMgo(opt)
 			if err != nil {
-				return fmt.Errorf("glib: mgo[%s] create err:%s", opt.Alias, err)
-			}
-			s.SetSyncTimeout(opt.TTL * time.Second)
-			s.SetSocketTimeout(opt.TTL * time.Second)
-
-			mgos[opt.Alias] = s
-			if err = s.Ping(); err != nil {
-				return fmt.Errorf("glib: mgo[%s] not health: %v", opt.Alias, err)
+				return err
 			}
-
-			// do not start a goroutine to ping, mgo.v2 already do it
+			a.mgos.Store(opt.Alias, c)
+			// do not start a goroutine to ping, the drivers already do it
 		}
 	}
 
 	return nil
 }
 
-func closeMgo() {
-	for _, m := range mgos {
-		m.Close()
+func openMgo(opt *mgoConfig) (*mgoClients, error) {
+	client, err := mongo.NewClient(mongoClientOptions(opt))
+	if err != nil {
+		return nil, fmt.Errorf("glib: mgo[%s] create err:%s", opt.Alias, err)
+	}
+	// the ping is bound by the server selection timeout if TTL is 0
+	ctx := context.Background()
+	if opt.TTL > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.TTL*time.Second)
+		defer cancel()
+	}
+	if err = client.Connect(ctx); err != nil {
+		return nil, fmt.Errorf("glib: mgo[%s] create err:%s", opt.Alias, err)
+	}
+	c := &mgoClients{opt: opt, client: client}
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("glib: mgo[%s] not health: %v", opt.Alias, err)
+	}
+	// the mgo.v2 session is dialed by the first MgoShareCopy/MgoShareClone
+	return c, nil
+}
+
+// mongoClientOptions returns the options of the official driver, the settings in dsn take precedence
+func mongoClientOptions(opt *mgoConfig) *mongoopt.ClientOptions {
+	o := mongoopt.Client().SetMonitor(newMongoMonitor(opt.Alias, time.Duration(opt.SlowThresholdMs)*time.Millisecond))
+	if opt.MaxPoolSize > 0 {
+		o.SetMaxPoolSize(opt.MaxPoolSize)
+	}
+	if opt.MinPoolSize > 0 {
+		o.SetMinPoolSize(opt.MinPoolSize)
+	}
+	if opt.MaxConnIdleTime > 0 {
+		o.SetMaxConnIdleTime(opt.MaxConnIdleTime * time.Second)
+	}
+	if opt.ConnectTimeout > 0 {
+		o.SetConnectTimeout(opt.ConnectTimeout * time.Second)
+	}
+	if opt.SocketTimeout > 0 {
+		o.SetSocketTimeout(opt.SocketTimeout * time.Second)
+	}
+	if opt.ServerSelectionTimeout > 0 {
+		o.SetServerSelectionTimeout(opt.ServerSelectionTimeout * time.Second)
+	}
+	// mgo.v2 accepts the dsn without scheme, eg: 127.0.0.1:27017/test
+	dsn := opt.Dsn
+	if !strings.HasPrefix(dsn, "mongodb://") && !strings.HasPrefix(dsn, "mongodb+srv://") {
+		dsn = "mongodb://" + dsn
 	}
+	return o.ApplyURI(dsn)
 }
@@ -0,0 +1,40 @@
+package glib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMongoClientOptions(t *testing.T) {
+	o := mongoClientOptions(&mgoConfig{
+		Dsn:            "127.0.0.1:27017/test?maxPoolSize=20",
+		MaxPoolSize:    50,
+		MinPoolSize:    5,
+		ConnectTimeout: 3,
+	})
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(o.Hosts) != 1 || o.Hosts[0] != "127.0.0.1:27017" {
+		t.Errorf("hosts=%v", o.Hosts)
+	}
+	// the one in dsn takes precedence
+	if *o.MaxPoolSize != 20 || *o.MinPoolSize != 5 || *o.ConnectTimeout != 3*time.Second {
+		t.Errorf("maxPoolSize=%d minPoolSize=%d connectTimeout=%s", *o.MaxPoolSize, *o.MinPoolSize, *o.ConnectTimeout)
+	}
+	if o.SocketTimeout != nil {
+		t.Errorf("socketTimeout should be the default, got %s", *o.SocketTimeout)
+	}
+}
+
+func TestMgoClients_legacySession(t *testing.T) {
+	// nothing listens on the port
+	c := &mgoClients{opt: &mgoConfig{Alias: "mgo", Dsn: "127.0.0.1:1", TTL: 1}}
+	if _, err := c.legacySession(); err == nil {
+		t.Fatal("dial should fail")
+	}
+	// the failed dial is not kept, it's retried by the next call
+	if c.legacy != nil {
+		t.Error("legacy session should be nil")
+	}
+}
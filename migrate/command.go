@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Command runs `up`, `down [n]` or `status` of alias with args, and prints the result to w.
+// it can be used by the service's own binary to run the go migrations, eg:
+//
+//	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+//		err := migrate.Command(glib.DB("db1"), "db1", os.Args[2:], os.Stdout)
+//	}
+func Command(db *gorm.DB, alias string, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: command required: up, down [n] or status")
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := Up(db, alias)
+		for _, v := range done {
+			fmt.Fprintf(w, "applied %d\n", v)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(w, "no pending migration")
+		}
+		return err
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("migrate: bad number of down %q", args[1])
+			}
+		}
+		done, err := Down(db, alias, n)
+		for _, v := range done {
+			fmt.Fprintf(w, "reverted %d\n", v)
+		}
+		return err
+	case "status":
+		list, err := Status(db, alias)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range list {
+			at := "pending"
+			if s.Applied {
+				at = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, at)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("migrate: unknown command %q", args[0])
+}
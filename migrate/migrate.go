@@ -0,0 +1,397 @@
+// Package migrate runs versioned schema migrations of the databases managed by glib.
+//
+// migrations are registered by the alias of glib-db, in go:
+//
+//	migrate.Register("db1", &migrate.Migration{
+//		Version: 1, Name: "create_user",
+//		Up:   func(tx *gorm.DB) error { return tx.CreateTable(&User{}).Error },
+//		Down: func(tx *gorm.DB) error { return tx.DropTable(&User{}).Error },
+//	})
+//
+// or in sql files named `<version>_<name>.up.sql` and `<version>_<name>.down.sql`:
+//
+//	migrate.LoadDir("db1", "./migrations")
+//
+// the applied versions are recorded in table glib_migration, and a row in table
+// glib_migration_lock prevents the replicas from running migrations at the same time.
+package migrate // import "github.com/carltd/glib/migrate"
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// ErrLocked is returned when the lock is held by others for longer than LockWait
+	ErrLocked = errors.New("migrate: locked by others")
+
+	// LockWait is the max time to wait for the lock held by others
+	LockWait = 30 * time.Second
+	// LockExpire is the time after which a lock is considered abandoned, eg: the holder crashed
+	LockExpire = 10 * time.Minute
+
+	lockRetryDelay = time.Second
+)
+
+// Migration is a version of the schema, Down may be nil if it can't be reverted
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus is the status of a migration
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// a migration applied, in table glib_migration
+type appliedMigration struct {
+	Version   int64 `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string { return "glib_migration" }
+
+// the lock, in table glib_migration_lock, there is one row at most
+type migrationLock struct {
+	ID       int `gorm:"primary_key;auto_increment:false"`
+	LockedBy string
+	LockedAt time.Time
+}
+
+func (migrationLock) TableName() string { return "glib_migration_lock" }
+
+var (
+	mu         sync.Mutex
+	migrations = make(map[string]map[int64]*Migration)
+)
+
+// Register adds the migration of the db alias, it panics if the version is registered twice.
+func Register(alias string, m *Migration) {
+	mu.Lock()
+	defer mu.Unlock()
+	if m == nil || m.Up == nil {
+		panic("migrate: Register migration without Up")
+	}
+	if migrations[alias] == nil {
+		migrations[alias] = make(map[int64]*Migration)
+	}
+	if _, dup := migrations[alias][m.Version]; dup {
+		panic(fmt.Sprintf("migrate: Register called twice for %s version %d", alias, m.Version))
+	}
+	migrations[alias][m.Version] = m
+}
+
+// RegisterSQL adds the migration of sql statements, see ExecSQL
+func RegisterSQL(alias string, version int64, name, up, down string) {
+	m := &Migration{Version: version, Name: name, Up: ExecSQL(up)}
+	if strings.TrimSpace(down) != "" {
+		m.Down = ExecSQL(down)
+	}
+	Register(alias, m)
+}
+
+var sqlFileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadDir registers the sql files in dir, which are named `<version>_<name>.up.sql`
+// and `<version>_<name>.down.sql`, eg: 0001_create_user.up.sql.
+func LoadDir(alias, dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type pair struct{ name, up, down string }
+	pairs := make(map[int64]*pair)
+	for _, f := range files {
+		match := sqlFileName.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		buf, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+		p := pairs[version]
+		if p == nil {
+			p = &pair{name: match[2]}
+			pairs[version] = p
+		}
+		if match[3] == "up" {
+			p.up = string(buf)
+		} else {
+			p.down = string(buf)
+		}
+	}
+
+	for version, p := range pairs {
+		if p.up == "" {
+			return fmt.Errorf("migrate: %s version %d has no up file", dir, version)
+		}
+		RegisterSQL(alias, version, p.name, p.up, p.down)
+	}
+	return nil
+}
+
+// ExecSQL returns a migration func which executes the statements in sql one by one,
+// the statements are separated by the lines end with `;`. the split is naive: a line of
+// a string literal, a comment or a procedure body ending with `;` splits the statement too,
+// use Register with a go func for such sql.
+func ExecSQL(sql string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		var stmt []string
+		for _, line := range strings.Split(sql, "\n") {
+			stmt = append(stmt, line)
+			if !strings.HasSuffix(strings.TrimSpace(line), ";") {
+				continue
+			}
+			if err := execStatement(tx, stmt); err != nil {
+				return err
+			}
+			stmt = stmt[:0]
+		}
+		return execStatement(tx, stmt)
+	}
+}
+
+func execStatement(tx *gorm.DB, lines []string) error {
+	s := strings.TrimSpace(strings.Join(lines, "\n"))
+	if s == "" || s == ";" {
+		return nil
+	}
+	return tx.Exec(s).Error
+}
+
+// Has reports whether the db alias has any migration registered
+func Has(alias string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return len(migrations[alias]) > 0
+}
+
+// sorted migrations of alias
+func registered(alias string) []*Migration {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]*Migration, 0, len(migrations[alias]))
+	for _, m := range migrations[alias] {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// Up applies the pending migrations of alias to db in version order, returns the versions applied.
+// every migration runs in a transaction, it stops at the first failed one.
+func Up(db *gorm.DB, alias string) (done []int64, err error) {
+	list := registered(alias)
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	release, err := lock(db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(release, &err)
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range list {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err = runInTx(db, m.Up, func(tx *gorm.DB) error {
+			return tx.Create(&appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migrate: %s up %d_%s: %v", alias, m.Version, m.Name, err)
+		}
+		done = append(done, m.Version)
+	}
+	return done, nil
+}
+
+// Down reverts the last n applied migrations of alias, returns the versions reverted.
+func Down(db *gorm.DB, alias string, n int) (done []int64, err error) {
+	byVersion := make(map[int64]*Migration)
+	for _, m := range registered(alias) {
+		byVersion[m.Version] = m
+	}
+
+	release, err := lock(db)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(release, &err)
+
+	if err = db.AutoMigrate(&appliedMigration{}).Error; err != nil {
+		return nil, err
+	}
+	var rows []*appliedMigration
+	if err = db.Order("version desc").Limit(n).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		m, ok := byVersion[row.Version]
+		if !ok || m.Down == nil {
+			return done, fmt.Errorf("migrate: %s down %d_%s: not revertible", alias, row.Version, row.Name)
+		}
+		err = runInTx(db, m.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&appliedMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migrate: %s down %d_%s: %v", alias, m.Version, m.Name, err)
+		}
+		done = append(done, m.Version)
+	}
+	return done, nil
+}
+
+// Status returns every registered or applied migration of alias in version order
+func Status(db *gorm.DB, alias string) ([]*MigrationStatus, error) {
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*MigrationStatus, 0)
+	for _, m := range registered(alias) {
+		s := &MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			s.Applied, s.AppliedAt = true, &row.AppliedAt
+			delete(applied, m.Version)
+		}
+		list = append(list, s)
+	}
+	// applied but not registered, eg: by a newer release
+	for _, row := range applied {
+		at := row.AppliedAt
+		list = append(list, &MigrationStatus{Version: row.Version, Name: row.Name, Applied: true, AppliedAt: &at})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func appliedVersions(db *gorm.DB) (map[int64]*appliedMigration, error) {
+	if err := db.AutoMigrate(&appliedMigration{}).Error; err != nil {
+		return nil, err
+	}
+	var rows []*appliedMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	m := make(map[int64]*appliedMigration, len(rows))
+	for _, row := range rows {
+		m[row.Version] = row
+	}
+	return m, nil
+}
+
+func runInTx(db *gorm.DB, fns ...func(tx *gorm.DB) error) (err error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+	for _, fn := range fns {
+		if err = fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// lock inserts the only row of glib_migration_lock, waits for LockWait if it exists,
+// the one older than LockExpire is removed. the row is refreshed by the holder until released,
+// so a long migration is not taken as abandoned.
+func lock(db *gorm.DB) (release func() error, err error) {
+	if err = db.AutoMigrate(&migrationLock{}).Error; err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), rand.Int63())
+	deadline := time.Now().Add(LockWait)
+	for {
+		if err = db.Delete(&migrationLock{}, "locked_at < ?", time.Now().Add(-LockExpire)).Error; err != nil {
+			return nil, err
+		}
+		err = db.Create(&migrationLock{ID: 1, LockedBy: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			return refreshLock(db, owner), nil
+		}
+		// the drivers report the conflict differently, so it's a conflict only if the row exists
+		if db.First(&migrationLock{}, "id = 1").Error != nil {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(lockRetryDelay)
+	}
+}
+
+// refreshLock updates locked_at of the lock of owner every LockExpire/3 until the release func is called
+func refreshLock(db *gorm.DB, owner string) (release func() error) {
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(LockExpire / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				err := db.Model(&migrationLock{}).Where("id = 1 AND locked_by = ?", owner).
+					Update("locked_at", time.Now()).Error
+				if err != nil {
+					log.Printf("migrate: refresh lock err: %v", err)
+				}
+			}
+		}
+	}()
+	return func() error {
+		close(stop)
+		<-done
+		return db.Delete(&migrationLock{}, "id = 1 AND locked_by = ?", owner).Error
+	}
+}
+
+// unlock calls release, its error is returned by err if there is no other one
+func unlock(release func() error, err *error) {
+	if e := release(); e != nil && *err == nil {
+		*err = fmt.Errorf("migrate: release lock: %v", e)
+	}
+}
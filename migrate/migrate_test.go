@@ -0,0 +1,128 @@
+package migrate
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	_ "github.com/carltd/glib/dialects/sqlite"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+func openTestDB(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open("sqlite3", "file:"+name+"?mode=memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	db.SingularTable(true)
+	return db
+}
+
+func TestUpDown(t *testing.T) {
+	Register("db1", &Migration{
+		Version: 1, Name: "create_user",
+		Up:   func(tx *gorm.DB) error { return tx.CreateTable(&user{}).Error },
+		Down: func(tx *gorm.DB) error { return tx.DropTable(&user{}).Error },
+	})
+	if err := LoadDir("db1", "testdata"); err != nil {
+		t.Fatal(err)
+	}
+	RegisterSQL("db1", 3, "bad", "INSERT INTO nothing VALUES (1);", "")
+
+	db := openTestDB(t, "updown")
+	defer db.Close()
+
+	done, err := Up(db, "db1")
+	if err == nil || !strings.Contains(err.Error(), "up 3_bad") || !reflect.DeepEqual(done, []int64{1, 2}) {
+		t.Fatalf("done=%v err=%v", done, err)
+	}
+	if !db.HasTable("user") || !db.HasTable("orders") {
+		t.Fatal("tables are not created")
+	}
+
+	list, err := Status(db, "db1")
+	if err != nil || len(list) != 3 || !list[0].Applied || !list[1].Applied || list[2].Applied {
+		t.Fatalf("status=%v err=%v", list, err)
+	}
+
+	var out bytes.Buffer
+	if err = Command(db, "db1", []string{"down", "2"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "reverted 2\nreverted 1\n" || db.HasTable("user") || db.HasTable("orders") {
+		t.Errorf("down output %q", out.String())
+	}
+}
+
+func TestLock(t *testing.T) {
+	db := openTestDB(t, "lock")
+	defer db.Close()
+
+	defer func(wait, delay time.Duration) { LockWait, lockRetryDelay = wait, delay }(LockWait, lockRetryDelay)
+	LockWait, lockRetryDelay = 30*time.Millisecond, 10*time.Millisecond
+
+	release, err := lock(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = lock(db); err != ErrLocked {
+		t.Errorf("err=%v, want ErrLocked", err)
+	}
+	release()
+	if release, err = lock(db); err != nil {
+		t.Fatal(err)
+	}
+	if err = release(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLockRefresh(t *testing.T) {
+	db := openTestDB(t, "lock_refresh")
+	defer db.Close()
+
+	defer func(wait, expire, delay time.Duration) {
+		LockWait, LockExpire, lockRetryDelay = wait, expire, delay
+	}(LockWait, LockExpire, lockRetryDelay)
+	LockWait, LockExpire, lockRetryDelay = 30*time.Millisecond, 150*time.Millisecond, 10*time.Millisecond
+
+	release, err := lock(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// held for longer than LockExpire, it's not taken as abandoned
+	time.Sleep(300 * time.Millisecond)
+	if _, err = lock(db); err != ErrLocked {
+		t.Errorf("err=%v, want ErrLocked", err)
+	}
+	if err = release(); err != nil {
+		t.Error(err)
+	}
+
+}
+
+func TestLockError(t *testing.T) {
+	db := openTestDB(t, "lock_error")
+	defer db.Close()
+
+	// the insert fails by the column unknown to lock, it's not a conflict to wait for
+	if err := db.Exec("CREATE TABLE glib_migration_lock (id integer primary key, note text not null)").Error; err != nil {
+		t.Fatal(err)
+	}
+	begin := time.Now()
+	if _, err := lock(db); err == nil || err == ErrLocked {
+		t.Errorf("err=%v, want the error of insert", err)
+	}
+	if time.Since(begin) >= lockRetryDelay {
+		t.Errorf("retried on the error of insert")
+	}
+}
@@ -0,0 +1,5 @@
+CREATE TABLE orders (
+    id INTEGER PRIMARY KEY,
+    user_id INTEGER NOT NULL
+);
+CREATE INDEX idx_orders_user ON orders (user_id);
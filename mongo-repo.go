@@ -0,0 +1,380 @@
+package glib
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	mongoopt "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// timeout of ensuring the indexes of all repositories by Init
+const mongoIndexTimeout = 30 * time.Second
+
+// MongoIndex declares an index of MongoRepo
+type MongoIndex struct {
+	// eg: bson.D{{Key: "uid", Value: 1}, {Key: "createdAt", Value: -1}}
+	Keys bson.D
+	// generated by the server if empty
+	Name   string
+	Unique bool
+	Sparse bool
+	// the documents expire after the time of the date field, 0 is never
+	ExpireAfter time.Duration
+}
+
+func (i *MongoIndex) model() mongo.IndexModel {
+	o := mongoopt.Index()
+	if i.Name != "" {
+		o.SetName(i.Name)
+	}
+	if i.Unique {
+		o.SetUnique(true)
+	}
+	if i.Sparse {
+		o.SetSparse(true)
+	}
+	if i.ExpireAfter > 0 {
+		o.SetExpireAfterSeconds(int32(i.ExpireAfter / time.Second))
+	}
+	return mongo.IndexModel{Keys: i.Keys, Options: o}
+}
+
+// MongoCursor is a page of the cursor-based pagination
+type MongoCursor struct {
+	// the documents are sorted by it, which should be unique, default is _id
+	Field string
+	Desc  bool
+	// value of Field of the last document in the previous page, nil for the first page
+	After interface{}
+	Size  int64
+}
+
+// MongoRepo is the repository of a collection, the documents are of the model type,
+// which is checked by the methods taking documents.
+type MongoRepo struct {
+	// nil for the default app, which is replaced by Init
+	app             *App
+	alias, db, coll string
+	typ             reflect.Type
+	indexes         []MongoIndex
+}
+
+var (
+	mongoReposMu sync.Mutex
+	mongoRepos   []*MongoRepo
+)
+
+// NewMongoRepo returns the repository of the collection of the default app, the documents are of the type
+// of model, which is a struct or a pointer to struct. the repositories created before Init have their indexes
+// ensured by Init, call EnsureIndexes for the ones created later.
+//
+//	var users = glib.NewMongoRepo("mgo", "shop", "user", User{},
+//		glib.MongoIndex{Keys: bson.D{{Key: "email", Value: 1}}, Unique: true})
+//
+//	var list []*User
+//	total, err := users.FindPage(ctx, bson.M{"vip": true}, bson.D{{Key: "_id", Value: -1}}, 1, 20, &list)
+func NewMongoRepo(alias, db, coll string, model interface{}, indexes ...MongoIndex) *MongoRepo {
+	r := newMongoRepo(nil, alias, db, coll, model, indexes)
+	mongoReposMu.Lock()
+	mongoRepos = append(mongoRepos, r)
+	mongoReposMu.Unlock()
+	return r
+}
+
+// NewMongoRepo returns the repository of the collection of the app, call EnsureIndexes to create its indexes.
+// see the package function NewMongoRepo
+func (a *App) NewMongoRepo(alias, db, coll string, model interface{}, indexes ...MongoIndex) *MongoRepo {
+	return newMongoRepo(a, alias, db, coll, model, indexes)
+}
+
+func newMongoRepo(a *App, alias, db, coll string, model interface{}, indexes []MongoIndex) *MongoRepo {
+	typ := reflect.TypeOf(model)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("glib: NewMongoRepo model should be a struct, got %T", model))
+	}
+	return &MongoRepo{app: a, alias: alias, db: db, coll: coll, typ: typ, indexes: indexes}
+}
+
+// Collection returns the collection of the app of the repository
+func (r *MongoRepo) Collection() (*mongo.Collection, error) {
+	a := r.app
+	if a == nil {
+		a = defaultApp
+	}
+	c, err := a.LookupMongo(r.alias)
+	if err != nil {
+		return nil, err
+	}
+	return c.Database(r.db).Collection(r.coll), nil
+}
+
+// EnsureIndexes creates the indexes declared, the existing ones are kept
+func (r *MongoRepo) EnsureIndexes(ctx context.Context) error {
+	coll, err := r.Collection()
+	if err != nil {
+		return err
+	}
+	return r.ensureIndexes(ctx, coll)
+}
+
+func (r *MongoRepo) ensureIndexes(ctx context.Context, coll *mongo.Collection) error {
+	if len(r.indexes) == 0 {
+		return nil
+	}
+	models := make([]mongo.IndexModel, len(r.indexes))
+	for i := range r.indexes {
+		models[i] = r.indexes[i].model()
+	}
+	if _, err := coll.Indexes().CreateMany(ctx, models); err != nil {
+		return fmt.Errorf("glib: mgo[%s] %s.%s ensure indexes err: %v", r.alias, r.db, r.coll, err)
+	}
+	return nil
+}
+
+// ensureMongoIndexes ensures the indexes of the repositories of the default app, by the app
+// which is going to be the default one
+func (a *App) ensureMongoIndexes(ctx context.Context) error {
+	mongoReposMu.Lock()
+	repos := append([]*MongoRepo(nil), mongoRepos...)
+	mongoReposMu.Unlock()
+
+	for _, r := range repos {
+		c, err := a.LookupMongo(r.alias)
+		if err != nil {
+			return fmt.Errorf("glib: mongo repo %s.%s of alias %s: %v", r.db, r.coll, r.alias, err)
+		}
+		if err = r.ensureIndexes(ctx, c.Database(r.db).Collection(r.coll)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDoc returns an error if doc is not the model or a pointer to it
+func (r *MongoRepo) checkDoc(doc interface{}) error {
+	t := reflect.TypeOf(doc)
+	if t == r.typ || (t != nil && t.Kind() == reflect.Ptr && t.Elem() == r.typ) {
+		return nil
+	}
+	return fmt.Errorf("glib: mongo repo %s.%s expects %s, got %T", r.db, r.coll, r.typ, doc)
+}
+
+// checkResult returns an error if result is not a pointer to the model
+func (r *MongoRepo) checkResult(result interface{}) error {
+	t := reflect.TypeOf(result)
+	if t != nil && t.Kind() == reflect.Ptr && t.Elem() == r.typ {
+		return nil
+	}
+	return fmt.Errorf("glib: mongo repo %s.%s expects *%s, got %T", r.db, r.coll, r.typ, result)
+}
+
+// checkResults returns an error if results is not a pointer to slice of the model or pointer to it
+func (r *MongoRepo) checkResults(results interface{}) error {
+	t := reflect.TypeOf(results)
+	if t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice {
+		e := t.Elem().Elem()
+		if e == r.typ || (e.Kind() == reflect.Ptr && e.Elem() == r.typ) {
+			return nil
+		}
+	}
+	return fmt.Errorf("glib: mongo repo %s.%s expects *[]%s or *[]*%s, got %T", r.db, r.coll, r.typ, r.typ, results)
+}
+
+// FindOne decodes the first document matched into result, which is a pointer to the model,
+// returns mongo.ErrNoDocuments if none is matched.
+func (r *MongoRepo) FindOne(ctx context.Context, filter, result interface{}, opts ...*mongoopt.FindOneOptions) error {
+	if err := r.checkResult(result); err != nil {
+		return err
+	}
+	coll, err := r.Collection()
+	if err != nil {
+		return err
+	}
+	return coll.FindOne(ctx, mongoFilter(filter), opts...).Decode(result)
+}
+
+// Find decodes the documents matched into results, which is a pointer to slice of the model
+func (r *MongoRepo) Find(ctx context.Context, filter, results interface{}, opts ...*mongoopt.FindOptions) error {
+	if err := r.checkResults(results); err != nil {
+		return err
+	}
+	coll, err := r.Collection()
+	if err != nil {
+		return err
+	}
+	cur, err := coll.Find(ctx, mongoFilter(filter), opts...)
+	if err != nil {
+		return err
+	}
+	return cur.All(ctx, results)
+}
+
+// Count returns the number of the documents matched
+func (r *MongoRepo) Count(ctx context.Context, filter interface{}) (int64, error) {
+	coll, err := r.Collection()
+	if err != nil {
+		return 0, err
+	}
+	return coll.CountDocuments(ctx, mongoFilter(filter))
+}
+
+// FindPage decodes the page(starts from 1) of the documents matched into results, returns the total number,
+// the documents are skipped by the server, so use FindAfter for deep pages.
+func (r *MongoRepo) FindPage(ctx context.Context, filter interface{}, sort bson.D, page, size int64, results interface{}) (int64, error) {
+	if page < 1 || size < 1 {
+		return 0, fmt.Errorf("glib: mongo repo page %d size %d", page, size)
+	}
+	total, err := r.Count(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	o := mongoopt.Find().SetSkip((page - 1) * size).SetLimit(size)
+	if len(sort) > 0 {
+		o.SetSort(sort)
+	}
+	return total, r.Find(ctx, filter, results, o)
+}
+
+// FindAfter decodes the documents matched after c.After into results, returns the cursor of the next page,
+// which is nil if it's the last page. c.Field may be a dotted path of the embedded documents, eg: profile.age.
+func (r *MongoRepo) FindAfter(ctx context.Context, filter interface{}, c MongoCursor, results interface{}) (next interface{}, err error) {
+	if err = r.checkResults(results); err != nil {
+		return nil, err
+	}
+	if c.Size < 1 {
+		return nil, fmt.Errorf("glib: mongo repo cursor size %d", c.Size)
+	}
+	coll, err := r.Collection()
+	if err != nil {
+		return nil, err
+	}
+
+	field, order, op := c.Field, 1, "$gt"
+	if field == "" {
+		field = "_id"
+	}
+	if c.Desc {
+		order, op = -1, "$lt"
+	}
+	filter = mongoFilter(filter)
+	if c.After != nil {
+		filter = bson.M{"$and": bson.A{filter, bson.M{field: bson.M{op: c.After}}}}
+	}
+	// one more document is fetched to know whether there is a next page
+	cur, err := coll.Find(ctx, filter, mongoopt.Find().SetSort(bson.D{{Key: field, Value: order}}).SetLimit(c.Size+1))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	list := reflect.ValueOf(results).Elem()
+	list.Set(list.Slice(0, 0))
+	elem := list.Type().Elem()
+	var (
+		last bson.RawValue
+		more bool
+	)
+	for cur.Next(ctx) {
+		if int64(list.Len()) == c.Size {
+			more = true
+			break
+		}
+		v := reflect.New(r.typ)
+		if err = cur.Decode(v.Interface()); err != nil {
+			return nil, err
+		}
+		if elem.Kind() != reflect.Ptr {
+			v = v.Elem()
+		}
+		list.Set(reflect.Append(list, v))
+		if last, err = cur.Current.LookupErr(strings.Split(field, ".")...); err != nil {
+			return nil, fmt.Errorf("glib: mongo repo %s.%s cursor field %s: %v", r.db, r.coll, field, err)
+		}
+	}
+	if err = cur.Err(); err != nil {
+		return nil, err
+	}
+	if !more {
+		return nil, nil
+	}
+	var after interface{}
+	if err = last.Unmarshal(&after); err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// Insert inserts the documents, which are the model or pointers to it
+func (r *MongoRepo) Insert(ctx context.Context, docs ...interface{}) error {
+	for _, doc := range docs {
+		if err := r.checkDoc(doc); err != nil {
+			return err
+		}
+	}
+	coll, err := r.Collection()
+	if err != nil {
+		return err
+	}
+	if len(docs) == 1 {
+		_, err = coll.InsertOne(ctx, docs[0])
+		return err
+	}
+	_, err = coll.InsertMany(ctx, docs)
+	return err
+}
+
+// Update applies update(eg: bson.M{"$set": ...}) to all the documents matched, returns the number modified
+func (r *MongoRepo) Update(ctx context.Context, filter, update interface{}) (int64, error) {
+	coll, err := r.Collection()
+	if err != nil {
+		return 0, err
+	}
+	res, err := coll.UpdateMany(ctx, mongoFilter(filter), update)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+// Upsert replaces the first document matched by doc, or inserts doc if none is matched
+func (r *MongoRepo) Upsert(ctx context.Context, filter, doc interface{}) error {
+	if err := r.checkDoc(doc); err != nil {
+		return err
+	}
+	coll, err := r.Collection()
+	if err != nil {
+		return err
+	}
+	_, err = coll.ReplaceOne(ctx, mongoFilter(filter), doc, mongoopt.Replace().SetUpsert(true))
+	return err
+}
+
+// Delete removes all the documents matched, returns the number deleted
+func (r *MongoRepo) Delete(ctx context.Context, filter interface{}) (int64, error) {
+	coll, err := r.Collection()
+	if err != nil {
+		return 0, err
+	}
+	res, err := coll.DeleteMany(ctx, mongoFilter(filter))
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// mongoFilter matches all if filter is nil, which is rejected by the driver
+func mongoFilter(filter interface{}) interface{} {
+	if filter == nil {
+		return bson.M{}
+	}
+	return filter
+}
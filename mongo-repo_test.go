@@ -0,0 +1,128 @@
+package glib
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type repoUser struct {
+	ID      int         `bson:"_id"`
+	Email   string      `bson:"email"`
+	Profile repoProfile `bson:"profile"`
+}
+
+type repoProfile struct {
+	Age int `bson:"age"`
+}
+
+func TestMongoRepo_check(t *testing.T) {
+	r := NewMongoRepo("mgo", "shop", "user", &repoUser{})
+	defer func() {
+		mongoReposMu.Lock()
+		mongoRepos = mongoRepos[:len(mongoRepos)-1]
+		mongoReposMu.Unlock()
+	}()
+
+	if r.checkDoc(repoUser{}) != nil || r.checkDoc(&repoUser{}) != nil || r.checkDoc(bson.M{}) == nil {
+		t.Error("checkDoc")
+	}
+	if r.checkResult(&repoUser{}) != nil || r.checkResult(repoUser{}) == nil {
+		t.Error("checkResult")
+	}
+	if r.checkResults(&[]repoUser{}) != nil || r.checkResults(&[]*repoUser{}) != nil || r.checkResults([]repoUser{}) == nil {
+		t.Error("checkResults")
+	}
+	// the documents of other types are rejected before the client is used
+	if err := r.Insert(context.Background(), &txItem{}); err == nil {
+		t.Error("Insert should check the type")
+	}
+	if _, err := r.FindPage(context.Background(), nil, nil, 0, 10, &[]repoUser{}); err == nil {
+		t.Error("page 0 should fail")
+	}
+	if _, err := r.Count(context.Background(), nil); err == nil {
+		t.Error("alias not configured should fail")
+	}
+
+	idx := (&MongoIndex{Keys: bson.D{{Key: "email", Value: 1}}, Unique: true, ExpireAfter: time.Hour}).model()
+	if !*idx.Options.Unique || *idx.Options.ExpireAfterSeconds != 3600 || idx.Options.Name != nil {
+		t.Errorf("index %+v", idx.Options)
+	}
+}
+
+func TestApp_ensureMongoIndexes(t *testing.T) {
+	NewMongoRepo("none", "shop", "user", repoUser{}, MongoIndex{Keys: bson.D{{Key: "email", Value: 1}}, Unique: true})
+	defer func() {
+		mongoReposMu.Lock()
+		mongoRepos = mongoRepos[:len(mongoRepos)-1]
+		mongoReposMu.Unlock()
+	}()
+
+	a := newApp()
+	defer a.Close()
+	// the indexes of the repository can't be ensured without its alias
+	err := a.ensureMongoIndexes(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "shop.user") || !strings.Contains(err.Error(), "none") {
+		t.Errorf("err=%v, want the one of repo shop.user and alias none", err)
+	}
+}
+
+func TestApp_MongoRepo(t *testing.T) {
+	a := newApp()
+	defer a.Close()
+	err := a.runMgoManager(&mgoConfig{Enable: true, Alias: "mgo", Dsn: "mongodb://127.0.0.1:27017/?serverSelectionTimeoutMS=1000", TTL: 2})
+	if err != nil {
+		t.Skipf("mongodb is not available: %v", err)
+	}
+	ctx := context.Background()
+	r := a.NewMongoRepo("mgo", "glib_test", "repo_user", repoUser{},
+		MongoIndex{Keys: bson.D{{Key: "email", Value: 1}}, Unique: true})
+	coll, err := r.Collection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = coll.Drop(ctx)
+	defer coll.Drop(ctx)
+	if err = r.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err = r.Insert(ctx, &repoUser{ID: 1, Email: "a", Profile: repoProfile{Age: 40}},
+		&repoUser{ID: 2, Email: "b", Profile: repoProfile{Age: 30}},
+		&repoUser{ID: 3, Email: "c", Profile: repoProfile{Age: 20}},
+		&repoUser{ID: 4, Email: "d", Profile: repoProfile{Age: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Insert(ctx, &repoUser{ID: 5, Email: "a"}); err == nil {
+		t.Error("the unique index is not ensured")
+	}
+
+	// 2 full pages by the embedded field, the last one has no next
+	var (
+		page  []repoUser
+		ids   []int
+		pages int
+		c     = MongoCursor{Field: "profile.age", Size: 2}
+	)
+	for ; pages < 3; pages++ {
+		next, err := r.FindAfter(ctx, nil, c, &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range page {
+			ids = append(ids, u.ID)
+		}
+		if next == nil {
+			pages++
+			break
+		}
+		c.After = next
+	}
+	if pages != 2 || len(ids) != 4 || ids[0] != 4 || ids[3] != 1 {
+		t.Errorf("ids=%v, want [4 3 2 1] in 2 pages", ids)
+	}
+}
@@ -0,0 +1,80 @@
+package glib
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/openzipkin/zipkin-go"
+	"go.mongodb.org/mongo-driver/event"
+
+	gtrace "github.com/carltd/glib/trace"
+)
+
+// mongoMonitor traces the commands of a mgo alias as the children of the span in their ctx,
+// and logs the ones slower than the threshold. the mgo.v2 sessions have no hook to do it.
+type mongoMonitor struct {
+	alias string
+	// 0 is not to log
+	slow time.Duration
+	// *mongoCommand by the request id
+	started sync.Map
+}
+
+type mongoCommand struct {
+	db, coll, op string
+	// nil if there is no span in ctx
+	span zipkin.Span
+}
+
+func newMongoMonitor(alias string, slow time.Duration) *event.CommandMonitor {
+	m := &mongoMonitor{alias: alias, slow: slow}
+	return &event.CommandMonitor{
+		Started: m.commandStarted,
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			m.commandFinished(&e.CommandFinishedEvent, "")
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			m.commandFinished(&e.CommandFinishedEvent, e.Failure)
+		},
+	}
+}
+
+func (m *mongoMonitor) commandStarted(ctx context.Context, e *event.CommandStartedEvent) {
+	c := &mongoCommand{db: e.DatabaseName, op: e.CommandName}
+	// the collection is the value of the first element, eg: {"find": "user", "filter": ...}
+	if elems, err := e.Command.Elements(); err == nil && len(elems) > 0 {
+		c.coll, _ = elems[0].Value().StringValueOK()
+	}
+	c.span, _ = gtrace.StartSpanFromContext(ctx, "mgo/"+m.alias+"/"+e.CommandName)
+	if c.span == nil && m.slow <= 0 {
+		return
+	}
+	m.started.Store(e.RequestID, c)
+}
+
+func (m *mongoMonitor) commandFinished(e *event.CommandFinishedEvent, failure string) {
+	v, ok := m.started.Load(e.RequestID)
+	if !ok {
+		return
+	}
+	m.started.Delete(e.RequestID)
+	c := v.(*mongoCommand)
+	d := time.Duration(e.DurationNanos)
+
+	if m.slow > 0 && d >= m.slow {
+		log.Printf("glib: mgo[%s] slow %s %s.%s took %s %s", m.alias, c.op, c.db, c.coll, d, failure)
+	}
+	if c.span == nil {
+		return
+	}
+	c.span.Tag("mgo.alias", m.alias)
+	c.span.Tag("mgo.database", c.db)
+	c.span.Tag("mgo.collection", c.coll)
+	c.span.Tag("mgo.operation", c.op)
+	if failure != "" {
+		zipkin.TagError.Set(c.span, failure)
+	}
+	c.span.Finish()
+}
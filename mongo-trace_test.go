@@ -0,0 +1,88 @@
+package glib
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+
+	gtrace "github.com/carltd/glib/trace"
+)
+
+func TestMongoMonitor_slow(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	m := newMongoMonitor("mgo", 100*time.Millisecond)
+	cmd, _ := bson.Marshal(bson.D{{Key: "find", Value: "user"}, {Key: "filter", Value: bson.M{}}})
+	for i, d := range []time.Duration{50 * time.Millisecond, 200 * time.Millisecond} {
+		m.Started(context.Background(), &event.CommandStartedEvent{Command: cmd, DatabaseName: "shop", CommandName: "find", RequestID: int64(i)})
+		m.Failed(context.Background(), &event.CommandFailedEvent{
+			CommandFinishedEvent: event.CommandFinishedEvent{DurationNanos: int64(d), CommandName: "find", RequestID: int64(i)},
+			Failure:              "timeout",
+		})
+	}
+
+	out := buf.String()
+	if strings.Count(out, "slow") != 1 || !strings.Contains(out, "mgo[mgo] slow find shop.user took 200ms timeout") {
+		t.Errorf("log: %s", out)
+	}
+}
+
+func TestMongoMonitor_trace(t *testing.T) {
+	rec := recorder.NewReporter()
+	if err := gtrace.InitTracerWithReporter(gtrace.TracerConfig{SrvName: "test"}, rec); err != nil {
+		t.Fatal(err)
+	}
+	defer gtrace.Close()
+	tracer, err := zipkin.NewTracer(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := tracer.StartSpan("parent")
+	ctx := zipkin.NewContext(context.Background(), parent)
+
+	m := &mongoMonitor{alias: "mgo"}
+	find, _ := bson.Marshal(bson.D{{Key: "find", Value: "user"}, {Key: "filter", Value: bson.M{}}})
+	insert, _ := bson.Marshal(bson.D{{Key: "insert", Value: "order"}})
+	m.commandStarted(ctx, &event.CommandStartedEvent{Command: find, DatabaseName: "shop", CommandName: "find", RequestID: 1})
+	m.commandStarted(ctx, &event.CommandStartedEvent{Command: insert, DatabaseName: "shop", CommandName: "insert", RequestID: 2})
+	// no parent span and no slow log, it's not kept
+	m.commandStarted(context.Background(), &event.CommandStartedEvent{Command: find, DatabaseName: "shop", CommandName: "find", RequestID: 3})
+	m.commandFinished(&event.CommandFinishedEvent{CommandName: "find", RequestID: 1}, "")
+	m.commandFinished(&event.CommandFinishedEvent{CommandName: "insert", RequestID: 2}, "duplicate key")
+	m.commandFinished(&event.CommandFinishedEvent{CommandName: "find", RequestID: 3}, "")
+
+	spans := rec.Flush()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans, want 2", len(spans))
+	}
+	for _, sp := range spans {
+		if sp.ParentID == nil || *sp.ParentID != parent.Context().ID || sp.TraceID != parent.Context().TraceID {
+			t.Errorf("%s is not a child of the parent", sp.Name)
+		}
+		if sp.Tags["mgo.alias"] != "mgo" || sp.Tags["mgo.database"] != "shop" {
+			t.Errorf("%s tags %v", sp.Name, sp.Tags)
+		}
+	}
+	if sp := spans[0]; sp.Name != "mgo/mgo/find" || sp.Tags["mgo.collection"] != "user" || sp.Tags["mgo.operation"] != "find" || sp.Tags["error"] != "" {
+		t.Errorf("%s tags %v", sp.Name, sp.Tags)
+	}
+	if sp := spans[1]; sp.Name != "mgo/mgo/insert" || sp.Tags["mgo.collection"] != "order" || sp.Tags["error"] != "duplicate key" {
+		t.Errorf("%s tags %v", sp.Name, sp.Tags)
+	}
+
+	m.started.Range(func(key, value interface{}) bool {
+		t.Errorf("command %v is not released", key)
+		return true
+	})
+}
@@ -1,6 +1,9 @@
 package glib
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type options struct {
 
@@ -16,6 +19,26 @@ type options struct {
 	// listen address for server
 	RunAt string
 
+	// backends of the config center, from the lowest precedence to the highest,
+	// consul at DiscoverAddr if it's empty
+	ConfigSources []ConfigSource
+
+	// AES key to decrypt the encrypted config values, see WithSecretKey
+	SecretKey     []byte
+	SecretKeyFile string
+
+	// register the service in consul at DiscoverAddr, see WithRegister
+	Register     bool
+	RegisterID   string
+	RegisterTags []string
+	RegisterTTL  time.Duration
+
+	// apply the pending migrations of every db alias when initializing, see WithMigrate
+	Migrate bool
+
+	// time to wait before closing the resources replaced by config changes
+	DrainTimeout time.Duration
+
 	// Other options for implementations of the interface
 	// can be stored in a context
 	Context context.Context
@@ -44,6 +67,66 @@ func WithRunAt(addr string) option {
 	}
 }
 
+// WithConfigSource - load config from the source instead of consul,
+// eg: NewFileSource("./conf"), NewEnvSource("DEMO_").
+// sources are stacked by the order of options, the later one overrides the former.
+func WithConfigSource(src ConfigSource) option {
+	return func(o *options) {
+		o.ConfigSources = append(o.ConfigSources, src)
+	}
+}
+
+// WithSecretKey - AES key(16, 24 or 32 bytes) to decrypt the config values in
+// format `enc:v1:...`, which are encrypted by `glib-secret encrypt`.
+// GLIB_SECRET_KEY(base64) or GLIB_SECRET_KEY_FILE are used if it's not given.
+func WithSecretKey(key []byte) option {
+	return func(o *options) {
+		o.SecretKey = key
+	}
+}
+
+// WithSecretKeyFile - file contains the AES key in base64, see WithSecretKey
+func WithSecretKeyFile(path string) option {
+	return func(o *options) {
+		o.SecretKeyFile = path
+	}
+}
+
+// WithDrainTimeout - when glib-db, glib-cache, glib-redis or glib-broker changed, the affected
+// resources are reopened, the old ones will be closed after the drain timeout, default is 10s
+func WithDrainTimeout(d time.Duration) option {
+	return func(o *options) {
+		o.DrainTimeout = d
+	}
+}
+
+// WithRegister - register the service named ServiceDomain at RunAt in consul, the TTL check
+// is kept passing while none of the resources is down, see Health.
+// the service is deregistered when the app is shut down.
+// id is `ServiceDomain-hostname-port` if it's empty.
+func WithRegister(id string, tags ...string) option {
+	return func(o *options) {
+		o.Register = true
+		o.RegisterID = id
+		o.RegisterTags = tags
+	}
+}
+
+// WithRegisterTTL - TTL of the registered service's check, default is 15s
+func WithRegisterTTL(ttl time.Duration) option {
+	return func(o *options) {
+		o.RegisterTTL = ttl
+	}
+}
+
+// WithMigrate - apply the pending migrations registered in package migrate to the master of
+// every enabled db alias when initializing, the replicas run them one by one by a lock table.
+func WithMigrate() option {
+	return func(o *options) {
+		o.Migrate = true
+	}
+}
+
 // WithNoStorage - none db, cache, mgo etc.
 func WithNoStorage() option {
 	return func(o *options) {
@@ -56,6 +139,8 @@ func newOptions(opts ...option) options {
 		ServiceDomain: "com.lonphy.example",
 		DiscoverAddr:  "127.0.0.1:8500",
 		NoStorage:     false,
+		DrainTimeout:  defaultDrainTimeout,
+		RegisterTTL:   defaultRegisterTTL,
 	}
 
 	for _, o := range opts {
@@ -31,6 +31,16 @@ func (c *kafkaConsumer) Subscribe(topic, group string) (queue.Subscriber, error)
 	return &kafkaSubscriber{c: consumer, serverVersion: c.opts.Version}, nil
 }
 
+// Ping connects the brokers with a short-lived client, the subscribers have their own
+func (c *kafkaConsumer) Ping() error {
+	client, err := sarama.NewClient(c.servers, &c.opts.Config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return ping(client)
+}
+
 func (c *kafkaConsumer) Close() error { return nil }
 
 type kafkaSubscriber struct {
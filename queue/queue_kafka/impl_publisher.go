@@ -10,6 +10,8 @@ import (
 type kafkaProducer struct {
 	serverVersion sarama.KafkaVersion
 	p             sarama.SyncProducer
+	// closed with p
+	client sarama.Client
 }
 
 // Unicast mode
@@ -38,6 +40,14 @@ func (c *kafkaProducer) Publish(topic string, msg *message.Message) error {
 	return err
 }
 
+func (c *kafkaProducer) Ping() error {
+	return ping(c.client)
+}
+
 func (c *kafkaProducer) Close() error {
-	return c.p.Close()
+	err := c.p.Close()
+	if e := c.client.Close(); err == nil {
+		err = e
+	}
+	return err
 }
@@ -26,12 +26,17 @@ func (d *kafkaQueueDriver) OpenPublisher(addr string) (queue.Publisher, error) {
 	cfg.Producer.Return.Successes = true
 	cfg.Producer.RequiredAcks = sarama.WaitForAll
 
-	client, err := sarama.NewSyncProducer(info.Servers, cfg)
+	client, err := sarama.NewClient(info.Servers, cfg)
 	if err != nil {
 		return nil, err
 	}
+	p, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
 
-	return &kafkaProducer{p: client}, nil
+	return &kafkaProducer{p: p, client: client}, nil
 }
 
 func (d *kafkaQueueDriver) OpenConsumer(addr string) (queue.Consumer, error) {
@@ -55,6 +60,11 @@ func (d *kafkaQueueDriver) OpenConsumer(addr string) (queue.Consumer, error) {
 	return ret, nil
 }
 
+// ping refreshes the metadata of the cluster, which fails if none of the brokers is reachable
+func ping(client sarama.Client) error {
+	return client.RefreshMetadata()
+}
+
 func init() {
 	queue.Register("kafka", new(kafkaQueueDriver))
 }
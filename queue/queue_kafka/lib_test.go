@@ -147,3 +147,26 @@ func BenchmarkKafkaSubscriber_NextMessage(b *testing.B) {
 		}
 	})
 }
+
+func TestPing(t *testing.T) {
+	pub, err := queue.NewPublisher(driverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	con, err := queue.NewConsumer(driverName, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	for _, c := range []interface{}{pub, con} {
+		p, ok := c.(interface{ Ping() error })
+		if !ok {
+			t.Fatalf("%T has no Ping", c)
+		}
+		if err = p.Ping(); err != nil {
+			t.Error(err)
+		}
+	}
+}
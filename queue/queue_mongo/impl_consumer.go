@@ -0,0 +1,229 @@
+package queue_mongo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/carltd/glib/queue"
+	"github.com/carltd/glib/queue/message"
+	"github.com/carltd/glib/queue/util"
+)
+
+// keys of message.Message.Options
+const (
+	// insert, update, replace, delete ...
+	OptionOperationType = "operationType"
+	// database.collection
+	OptionNamespace = "ns"
+	// the _id of the document, in extended json
+	OptionDocumentKey = "documentKey"
+	// the updated and removed fields of update, in extended json
+	OptionUpdateDescription = "updateDescription"
+)
+
+type mongoConsumer struct {
+	client *mongo.Client
+	info   *dialInfo
+	// nil to save the tokens in the database of the subject
+	store TokenStore
+	// the client is disconnected by Close
+	owned bool
+}
+
+// Unicast mode
+func (c *mongoConsumer) Dequeue(subject, group string, timeout time.Duration, msg proto.Message) (*message.Meta, error) {
+	return nil, ErrNotSupport
+}
+
+// Broadcast mode, subject is `database` or `database.collection`,
+// the changes of the token collection are not delivered by the database one
+func (c *mongoConsumer) Subscribe(subject, group string) (queue.Subscriber, error) {
+	ns := strings.SplitN(subject, ".", 2)
+	if ns[0] == "" {
+		return nil, errors.New("queue mongo: subject should be database or database.collection")
+	}
+	db := c.client.Database(ns[0])
+
+	s := &mongoSubscriber{events: make(chan *changeEvent), done: make(chan struct{})}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	opts := options.ChangeStream().SetFullDocument(c.info.FullDocument)
+	if group != "" {
+		s.key, s.store = group+":"+subject, c.store
+		if s.store == nil {
+			s.store = NewTokenStore(db.Collection(c.info.TokenCollection))
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, operationTimeout)
+		token, err := s.store.Load(ctx, s.key)
+		cancel()
+		if err != nil {
+			s.cancel()
+			return nil, err
+		}
+		if token != nil {
+			opts.SetResumeAfter(token)
+		}
+	}
+
+	var (
+		cs  *mongo.ChangeStream
+		err error
+	)
+	if len(ns) == 1 {
+		// the tokens saved into the database are not events, or every save makes another one
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"ns.coll": bson.M{"$ne": c.info.TokenCollection}}}}}
+		cs, err = db.Watch(s.ctx, pipeline, opts)
+	} else {
+		cs, err = db.Collection(ns[1]).Watch(s.ctx, mongo.Pipeline{}, opts)
+	}
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	s.cs = cs
+	go s.watch()
+	return s, nil
+}
+
+func (c *mongoConsumer) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+	return c.client.Ping(ctx, readpref.Primary())
+}
+
+func (c *mongoConsumer) Close() error {
+	if c.owned {
+		return c.client.Disconnect(context.Background())
+	}
+	return nil
+}
+
+type changeEvent struct {
+	// the resume token
+	ID            bson.Raw `bson:"_id"`
+	OperationType string   `bson:"operationType"`
+	NS            struct {
+		DB   string `bson:"db"`
+		Coll string `bson:"coll"`
+	} `bson:"ns"`
+	DocumentKey       bson.Raw `bson:"documentKey"`
+	FullDocument      bson.Raw `bson:"fullDocument"`
+	UpdateDescription bson.Raw `bson:"updateDescription"`
+}
+
+// toMessage maps the event, Body is the full document in bson, which is empty for delete,
+// or update if full_document is default.
+func (e *changeEvent) toMessage() *message.Message {
+	msg := &message.Message{
+		MessageId: util.GenMsgID(),
+		Options: map[string]string{
+			OptionOperationType: e.OperationType,
+			OptionNamespace:     e.NS.DB,
+		},
+		Body: e.FullDocument,
+	}
+	if e.NS.Coll != "" {
+		msg.Options[OptionNamespace] += "." + e.NS.Coll
+	}
+	if data, ok := e.ID.Lookup("_data").StringValueOK(); ok {
+		msg.MessageId = data
+	}
+	if e.DocumentKey != nil {
+		buf, _ := bson.MarshalExtJSON(e.DocumentKey, false, false)
+		msg.Options[OptionDocumentKey] = string(buf)
+	}
+	if e.UpdateDescription != nil {
+		buf, _ := bson.MarshalExtJSON(e.UpdateDescription, false, false)
+		msg.Options[OptionUpdateDescription] = string(buf)
+	}
+	return msg
+}
+
+type mongoSubscriber struct {
+	cs     *mongo.ChangeStream
+	store  TokenStore
+	key    string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	events chan *changeEvent
+	// closed when watch returns, err is the reason
+	done chan struct{}
+	err  error
+	// token of the event delivered but not saved yet
+	pending bson.Raw
+
+	closeOnce sync.Once
+}
+
+// watch reads the stream and hands the events to NextMessage one by one
+func (s *mongoSubscriber) watch() {
+	defer close(s.done)
+	for s.cs.Next(s.ctx) {
+		e := &changeEvent{}
+		if s.err = s.cs.Decode(e); s.err != nil {
+			return
+		}
+		select {
+		case s.events <- e:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+	s.err = s.cs.Err()
+}
+
+// NextMessage saves the token of the previous message, which is considered handled, then waits for the next one.
+func (s *mongoSubscriber) NextMessage(timeout time.Duration) (*message.Message, error) {
+	if err := s.savePending(); err != nil {
+		return nil, err
+	}
+
+	select {
+	case e := <-s.events:
+		s.pending = e.ID
+		return e.toMessage(), nil
+	case <-s.done:
+		if s.err == nil {
+			s.err = errors.New("queue mongo: change stream closed")
+		}
+		return nil, s.err
+	case <-time.After(timeout):
+		return nil, queue.ErrTimeout
+	}
+}
+
+func (s *mongoSubscriber) savePending() error {
+	if s.pending == nil || s.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+	if err := s.store.Save(ctx, s.key, s.pending); err != nil {
+		return err
+	}
+	s.pending = nil
+	return nil
+}
+
+// Close saves the token of the last message, and closes the stream
+func (s *mongoSubscriber) Close() (err error) {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		<-s.done
+		err = s.savePending()
+		if e := s.cs.Close(context.Background()); err == nil {
+			err = e
+		}
+	})
+	return
+}
@@ -0,0 +1,111 @@
+package queue_mongo_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/carltd/glib/queue"
+	"github.com/carltd/glib/queue/queue_mongo"
+)
+
+const (
+	driverName    = "mongo"
+	dsn           = "mongodb://127.0.0.1:27017/?serverSelectionTimeoutMS=1000&token_collection=test_resume_token"
+	testSubject   = "glib_test.events"
+	consumerGroup = "test"
+)
+
+func TestChangeStream(t *testing.T) {
+	if _, err := queue.NewPublisher(driverName, dsn); err != queue_mongo.ErrNotSupport {
+		t.Errorf("publisher want ErrNotSupport, got %v", err)
+	}
+
+	con, err := queue.NewConsumer(driverName, dsn)
+	if err != nil {
+		t.Skipf("mongodb(replica set) is not available: %v", err)
+	}
+	defer con.Close()
+
+	client, _ := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:27017"))
+	defer client.Disconnect(context.Background())
+	db := client.Database("glib_test")
+	_ = db.Drop(context.Background())
+
+	sub, err := con.Subscribe(testSubject, consumerGroup)
+	if err != nil {
+		t.Skipf("change stream is not available: %v", err)
+	}
+	for i := 1; i <= 2; i++ {
+		if _, err = db.Collection("events").InsertOne(context.Background(), bson.M{"_id": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg, err := sub.NextMessage(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Options[queue_mongo.OptionOperationType] != "insert" || msg.Options[queue_mongo.OptionNamespace] != testSubject {
+		t.Errorf("options %v", msg.Options)
+	}
+	var doc bson.M
+	if err = bson.Unmarshal(msg.Body, &doc); err != nil || doc["_id"] != int32(1) {
+		t.Errorf("body %v, err %v", doc, err)
+	}
+	// the first one is handled, the second one is delivered again after restart
+	if _, err = sub.NextMessage(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	_ = sub.Close()
+
+	// the token of the last message is saved by Close, so nothing is left
+	sub, err = con.Subscribe(testSubject, consumerGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if _, err = sub.NextMessage(time.Second); err != queue.ErrTimeout {
+		t.Errorf("want timeout after resume, got %v", err)
+	}
+}
+
+func TestChangeStream_Database(t *testing.T) {
+	con, err := queue.NewConsumer(driverName, dsn)
+	if err != nil {
+		t.Skipf("mongodb(replica set) is not available: %v", err)
+	}
+	defer con.Close()
+
+	client, _ := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:27017"))
+	defer client.Disconnect(context.Background())
+	db := client.Database("glib_test_db")
+	_ = db.Drop(context.Background())
+
+	sub, err := con.Subscribe("glib_test_db", consumerGroup)
+	if err != nil {
+		t.Skipf("change stream is not available: %v", err)
+	}
+	defer sub.Close()
+
+	for i := 1; i <= 2; i++ {
+		if _, err = db.Collection("events").InsertOne(context.Background(), bson.M{"_id": i}); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := sub.NextMessage(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Options[queue_mongo.OptionNamespace] != "glib_test_db.events" {
+			t.Errorf("message of %s", msg.Options[queue_mongo.OptionNamespace])
+		}
+	}
+	// the tokens saved by the calls above are not delivered
+	if msg, err := sub.NextMessage(time.Second); err != queue.ErrTimeout {
+		t.Errorf("want timeout, got %v of %v", err, msg)
+	}
+}
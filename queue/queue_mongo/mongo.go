@@ -0,0 +1,69 @@
+// Package queue_mongo consumes the change streams of mongodb as queue messages,
+// the subject is `database` or `database.collection`, Publisher is not supported.
+//
+// the resume token of the last handled event is saved by the consumer group, so a restarted
+// subscriber of the same group and subject continues where it stopped, the events are
+// delivered at least once. the subscriber without group starts from now.
+package queue_mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+
+	"github.com/carltd/glib/queue"
+)
+
+var (
+	ErrNotSupport = errors.New("queue mongo: Not Support")
+)
+
+// timeout of the operations other than watching, eg: connect, save token
+const operationTimeout = 10 * time.Second
+
+type mongoQueueDriver struct{}
+
+func (d *mongoQueueDriver) OpenPublisher(addr string) (queue.Publisher, error) {
+	return nil, ErrNotSupport
+}
+
+func (d *mongoQueueDriver) OpenConsumer(addr string) (queue.Consumer, error) {
+	info, err := parseURL(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := mongo.NewClient(options.Client().ApplyURI(info.URI))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+	if err = client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	if err = client.Ping(ctx, readpref.Primary()); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, err
+	}
+	return &mongoConsumer{client: client, info: info, owned: true}, nil
+}
+
+// NewConsumer returns the consumer of the change streams by client, eg: glib.Mongo("mgo"),
+// which is not disconnected by the consumer. the tokens are saved in store, or collection
+// glib_resume_token of the subject's database if store is nil.
+func NewConsumer(client *mongo.Client, store TokenStore) queue.Consumer {
+	return &mongoConsumer{
+		client: client,
+		info:   &dialInfo{TokenCollection: defaultTokenCollection, FullDocument: options.UpdateLookup},
+		store:  store,
+	}
+}
+
+func init() {
+	queue.Register("mongo", new(mongoQueueDriver))
+}
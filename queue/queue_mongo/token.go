@@ -0,0 +1,48 @@
+package queue_mongo
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TokenStore persists the resume tokens of the change streams, key is `group:subject`
+type TokenStore interface {
+	// Load returns nil if there is no token of key
+	Load(ctx context.Context, key string) (bson.Raw, error)
+	Save(ctx context.Context, key string, token bson.Raw) error
+}
+
+type collectionTokenStore struct {
+	coll *mongo.Collection
+}
+
+// NewTokenStore returns the store which saves the tokens in coll, one document for each key
+func NewTokenStore(coll *mongo.Collection) TokenStore {
+	return &collectionTokenStore{coll: coll}
+}
+
+type tokenDoc struct {
+	Key       string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+func (s *collectionTokenStore) Load(ctx context.Context, key string) (bson.Raw, error) {
+	var doc tokenDoc
+	err := s.coll.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return doc.Token, err
+}
+
+func (s *collectionTokenStore) Save(ctx context.Context, key string, token bson.Raw) error {
+	_, err := s.coll.ReplaceOne(ctx, bson.M{"_id": key},
+		&tokenDoc{Key: key, Token: token, UpdatedAt: time.Now()},
+		options.Replace().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,54 @@
+package queue_mongo
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestParseURL(t *testing.T) {
+	info, err := parseURL("mongodb://u:p@h1:27017,h2:27017/admin?replicaSet=rs0&token_collection=tokens&full_document=default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.URI != "mongodb://u:p@h1:27017,h2:27017/admin?replicaSet=rs0" {
+		t.Errorf("uri %s", info.URI)
+	}
+	if info.TokenCollection != "tokens" || info.FullDocument != options.Default {
+		t.Errorf("%+v", info)
+	}
+
+	if info, _ = parseURL("mongodb://h1:27017"); info.TokenCollection != defaultTokenCollection || info.FullDocument != options.UpdateLookup {
+		t.Errorf("defaults %+v", info)
+	}
+}
+
+func TestChangeEvent_toMessage(t *testing.T) {
+	buf, _ := bson.Marshal(bson.M{
+		"_id":           bson.M{"_data": "826000"},
+		"operationType": "update",
+		"ns":            bson.M{"db": "shop", "coll": "orders"},
+		"documentKey":   bson.M{"_id": 1},
+		"fullDocument":  bson.M{"_id": 1, "paid": true},
+		"updateDescription": bson.M{
+			"updatedFields": bson.M{"paid": true},
+		},
+	})
+	e := &changeEvent{}
+	if err := bson.Unmarshal(buf, e); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := e.toMessage()
+	if msg.MessageId != "826000" || msg.Options[OptionOperationType] != "update" || msg.Options[OptionNamespace] != "shop.orders" {
+		t.Errorf("%+v", msg)
+	}
+	if msg.Options[OptionDocumentKey] != `{"_id":1}` {
+		t.Errorf("documentKey %s", msg.Options[OptionDocumentKey])
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(msg.Body, &doc); err != nil || doc["paid"] != true {
+		t.Errorf("body %v, err %v", doc, err)
+	}
+}
@@ -1,46 +1,63 @@
 package glib
 
 import (
-	"context"
 	"fmt"
-	"sync"
 
 	"github.com/carltd/glib/redis_wrapper"
 )
 
 type redisConfig struct {
 	Enable bool   `json:"enable"`
-	Alias  string `json:"alias"`
-	Dsn    string `json:"dsn"`
+	Alias  string `json:"alias" validate:"required,unique"`
+	Dsn    string `json:"dsn" validate:"required"`
 }
 
-var rediss sync.Map
-
+// Redis will return the redis wrapper of alias, panic if it's not exists
 func Redis(alias string) redis_wrapper.RedisWrapper {
-	eg, ok := rediss.Load(alias)
+	return defaultApp.Redis(alias)
+}
+
+// LookupRedis is like Redis, but returns *ErrAliasNotConfigured if it's not exists
+func LookupRedis(alias string) (redis_wrapper.RedisWrapper, error) {
+	return defaultApp.LookupRedis(alias)
+}
+
+// Redis - see the package function Redis
+func (a *App) Redis(alias string) redis_wrapper.RedisWrapper {
+	r, err := a.LookupRedis(alias)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// LookupRedis - see the package function LookupRedis
+func (a *App) LookupRedis(alias string) (redis_wrapper.RedisWrapper, error) {
+	eg, ok := a.rediss.Load(alias)
 	if !ok {
-		panic(fmt.Errorf("glib: redis[%s] not configed", alias))
+		return nil, &ErrAliasNotConfigured{Kind: KindRedis, Alias: alias}
 	}
-	return eg.(redis_wrapper.RedisWrapper)
+	return eg.(redis_wrapper.RedisWrapper), nil
 }
 
-func runRedisManger(ctx context.Context, opts ...*redisConfig) error {
+func (a *App) runRedisManger(opts ...*redisConfig) error {
 	for _, opt := range opts {
 		if opt.Enable {
-			c, err := redis_wrapper.Open(opt.Dsn)
+			c, err := openRedis(opt)
 			if err != nil {
-				return fmt.Errorf("glib: redis[%s] create err:%v", opt.Alias, err)
+				return err
 			}
-			rediss.Store(opt.Alias, c)
+			a.rediss.Store(opt.Alias, c)
 		}
 	}
 
 	return nil
 }
 
-func closeRedis() {
-	rediss.Range(func(key, value interface{}) bool {
-		err := value.(redis_wrapper.RedisWrapper).Close()
-		return err != nil
-	})
+func openRedis(opt *redisConfig) (redis_wrapper.RedisWrapper, error) {
+	c, err := redis_wrapper.Open(opt.Dsn)
+	if err != nil {
+		return nil, fmt.Errorf("glib: redis[%s] create err:%v", opt.Alias, err)
+	}
+	return c, nil
 }
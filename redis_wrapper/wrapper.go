@@ -267,6 +267,14 @@ func (w *redisWrapper) Raw() redis.Conn {
 	return w.pool.Get()
 }
 
+// Ping checks the connection of server, it's not in RedisWrapper to keep the other implementations working
+func (w *redisWrapper) Ping() error {
+	conn := w.pool.Get()
+	_, err := conn.Do("PING")
+	conn.Close()
+	return err
+}
+
 func (w *redisWrapper) Close() error {
 	return w.pool.Close()
 }
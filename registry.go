@@ -0,0 +1,110 @@
+package glib
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// default TTL of the registered service's check
+	defaultRegisterTTL = 15 * time.Second
+	// the service is removed by consul after its check is critical for a while
+	registerDeregisterAfter = "1m"
+)
+
+// register the service in consul with a TTL check,
+// which is updated by the health of the resources until the app is stopped.
+func (a *App) register() error {
+	opts := a.conf.Options()
+	host, portStr, err := net.SplitHostPort(opts.RunAt)
+	if err != nil {
+		return fmt.Errorf("glib: register service at %q: %v", opts.RunAt, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("glib: register service at %q: bad port", opts.RunAt)
+	}
+	// let consul use the address of the agent
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = ""
+	}
+
+	id := opts.RegisterID
+	if id == "" {
+		hostname, _ := os.Hostname()
+		id = opts.ServiceDomain + "-" + hostname + "-" + portStr
+	}
+
+	client, err := a.conf.consulClient()
+	if err != nil {
+		return fmt.Errorf("glib: register service %s: %v", id, err)
+	}
+	agent := client.Agent()
+	checkID := "service:" + id
+	err = agent.ServiceRegister(&api.AgentServiceRegistration{
+		ID:      id,
+		Name:    opts.ServiceDomain,
+		Tags:    opts.RegisterTags,
+		Address: host,
+		Port:    port,
+		Check: &api.AgentServiceCheck{
+			CheckID:                        checkID,
+			TTL:                            opts.RegisterTTL.String(),
+			DeregisterCriticalServiceAfter: registerDeregisterAfter,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("glib: register service %s: %v", id, err)
+	}
+
+	a.registered = &closing{kind: KindService, alias: id, close: func() error {
+		return agent.ServiceDeregister(id)
+	}}
+
+	a.updateTTL(agent, checkID, opts.RegisterTTL)
+	go func() {
+		// update before the check expires
+		t := time.NewTicker(opts.RegisterTTL / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-t.C:
+				a.updateTTL(agent, checkID, opts.RegisterTTL)
+			}
+		}
+	}()
+	return nil
+}
+
+// updateTTL passes the check if none of the resources is down
+func (a *App) updateTTL(agent *api.Agent, checkID string, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(a.ctx, ttl/3)
+	defer cancel()
+
+	status, output := api.HealthPassing, ""
+	var down []string
+	for _, s := range a.Health(ctx) {
+		if s.Status == HealthDown {
+			down = append(down, s.Kind+"["+s.Alias+"]: "+s.LastError)
+		}
+	}
+	if len(down) > 0 {
+		status, output = api.HealthCritical, strings.Join(down, "\n")
+	}
+	if a.ctx.Err() != nil {
+		return
+	}
+	if err := agent.UpdateTTL(checkID, output, status); err != nil {
+		log.Printf("glib: update check %s err: %v", checkID, err)
+	}
+}
@@ -0,0 +1,88 @@
+package glib
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// fakeAgent records the requests of the consul agent api
+type fakeAgent struct {
+	mu       sync.Mutex
+	service  *api.AgentServiceRegistration
+	statuses []string
+	removed  string
+}
+
+func (f *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	buf, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		f.service = &api.AgentServiceRegistration{}
+		_ = json.Unmarshal(buf, f.service)
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/update/"):
+		var v struct{ Status string }
+		_ = json.Unmarshal(buf, &v)
+		f.statuses = append(f.statuses, v.Status)
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		f.removed = strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/")
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestApp_Register(t *testing.T) {
+	agent := &fakeAgent{}
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+
+	os.Setenv("GLIB_REG_GLIB_SUPPORTS", `{}`)
+	defer os.Unsetenv("GLIB_REG_GLIB_SUPPORTS")
+
+	a, err := New(
+		WithServiceDomain("com.carltd.srv.demo"),
+		WithConfigSource(NewEnvSource("GLIB_REG_")),
+		WithDiscoverAddr(strings.TrimPrefix(srv.URL, "http://")),
+		WithRunAt("0.0.0.0:8080"),
+		WithRegister("demo-1", "v1"),
+		WithRegisterTTL(30*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agent.mu.Lock()
+	s := agent.service
+	agent.mu.Unlock()
+	if s == nil || s.ID != "demo-1" || s.Name != "com.carltd.srv.demo" || s.Port != 8080 || s.Address != "" ||
+		len(s.Tags) != 1 || s.Check == nil || s.Check.TTL != "30ms" {
+		t.Fatalf("registered %+v", s)
+	}
+
+	// the check is critical when any resource is down
+	a.rediss.Store("r1", &fakePinger{err: errors.New("down")})
+	time.Sleep(50 * time.Millisecond)
+
+	if err = a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	if agent.statuses[0] != api.HealthPassing || agent.statuses[len(agent.statuses)-1] != api.HealthCritical {
+		t.Errorf("check statuses %v", agent.statuses)
+	}
+	if agent.removed != "demo-1" {
+		t.Errorf("deregistered %q", agent.removed)
+	}
+}
@@ -0,0 +1,239 @@
+package glib
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/carltd/glib/internal"
+)
+
+// default time to wait before closing a replaced or removed resource
+const defaultDrainTimeout = 10 * time.Second
+
+// reloadable is a kind of resource which can be reconfigured at runtime,
+// every enabled config item is identified by an unique id, eg: alias.
+type reloadable struct {
+	key string
+
+	// parse returns the enabled config items by id
+	parse func(buf []byte) (map[string]interface{}, error)
+	open  func(cfg interface{}) (interface{}, error)
+	// store of the resource opened by cfg, and the alias in it
+	store func(cfg interface{}) (*sync.Map, string)
+
+	mu sync.Mutex
+	// config of the items opened by id, the ones failed to open are not in it
+	applied map[string]interface{}
+	// retries the items failed to open, nil if there is not any
+	retry *time.Timer
+}
+
+// time to wait before opening the items failed to open again
+var reloadRetryDelay = 30 * time.Second
+
+// seed records the items of cur opened by Init
+func (r *reloadable) seed(cur ConfigObject) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.applied = make(map[string]interface{})
+	items, err := r.parse(cur.Bytes())
+	if err != nil {
+		return
+	}
+	for id, cfg := range items {
+		store, alias := r.store(cfg)
+		if _, ok := store.Load(alias); ok {
+			r.applied[id] = cfg
+		}
+	}
+}
+
+// reload applies the config to the items opened, resources of the new or changed items are opened
+// and replace the old ones, removed items are deleted, the old resources are closed after drain.
+// the items failed to open are retried by the next reload, which is scheduled by retry after reloadRetryDelay.
+func (r *reloadable) reload(cur ConfigObject, drain time.Duration, retry func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.retry != nil {
+		r.retry.Stop()
+		r.retry = nil
+	}
+
+	items, err := r.parse(cur.Bytes())
+	if err != nil {
+		log.Printf("glib: ignore changes of %s: %v", r.key, err)
+		return
+	}
+
+	failed := false
+	for id, cfg := range items {
+		if applied, ok := r.applied[id]; ok && reflect.DeepEqual(applied, cfg) {
+			continue
+		}
+		res, err := r.open(cfg)
+		if err != nil {
+			log.Printf("glib: reload %s[%s] err: %v", r.key, id, err)
+			failed = true
+			continue
+		}
+		store, alias := r.store(cfg)
+		prev, loaded := store.Load(alias)
+		store.Store(alias, res)
+		if loaded {
+			closeAfter(drain, r.key+"["+id+"]", prev)
+		}
+		r.applied[id] = cfg
+		log.Printf("glib: %s[%s] reloaded", r.key, id)
+	}
+
+	for id, cfg := range r.applied {
+		if _, ok := items[id]; ok {
+			continue
+		}
+		delete(r.applied, id)
+		store, alias := r.store(cfg)
+		if prev, loaded := store.Load(alias); loaded {
+			store.Delete(alias)
+			closeAfter(drain, r.key+"["+id+"]", prev)
+			log.Printf("glib: %s[%s] removed", r.key, id)
+		}
+	}
+
+	if failed && retry != nil {
+		r.retry = time.AfterFunc(reloadRetryDelay, retry)
+	}
+}
+
+// closeAfter closes the resource after the drain time,
+// the requests started before it was replaced have a chance to finish
+func closeAfter(drain time.Duration, name string, res interface{}) {
+	c, ok := res.(io.Closer)
+	if !ok {
+		return
+	}
+	time.AfterFunc(drain, func() {
+		if err := c.Close(); err != nil {
+			log.Printf("glib: close %s err: %v", name, err)
+		}
+	})
+}
+
+// watchResources reloads the resources of the enabled features when their config changed
+func (a *App) watchResources() {
+	var (
+		cc      = a.conf
+		enabled = a.enabled
+		drain   = cc.Options().DrainTimeout
+	)
+	watch := func(r *reloadable) {
+		r.seed(cc.Raw(r.key))
+		var retry func()
+		retry = func() {
+			select {
+			case <-a.ctx.Done():
+			default:
+				r.reload(cc.Raw(r.key), drain, retry)
+			}
+		}
+		cc.AddWatcher(r.key, func(old, new ConfigObject) {
+			r.reload(new, drain, retry)
+		})
+	}
+
+	if enabled.Db {
+		watch(&reloadable{
+			key: glibConfigDb,
+			parse: func(buf []byte) (map[string]interface{}, error) {
+				return parseReloadItems(cc, glibConfigDb, buf, &[]*dbConfig{}, func(cfg interface{}) string {
+					return cfg.(*dbConfig).Alias
+				})
+			},
+			open: func(cfg interface{}) (interface{}, error) {
+				opt := cfg.(*dbConfig)
+				g, err := a.openDBGroup(opt)
+				if err == nil && opt.TTL > 0 {
+					go a.dbHealthCheck(opt.TTL, opt.Alias, g)
+				}
+				return g, err
+			},
+			store: func(cfg interface{}) (*sync.Map, string) { return &a.dbs, cfg.(*dbConfig).Alias },
+		})
+	}
+
+	if enabled.Cache {
+		watch(&reloadable{
+			key: glibConfigCache,
+			parse: func(buf []byte) (map[string]interface{}, error) {
+				return parseReloadItems(cc, glibConfigCache, buf, &[]*internal.CacheConfig{}, func(cfg interface{}) string {
+					return cfg.(*internal.CacheConfig).Alias
+				})
+			},
+			open: func(cfg interface{}) (interface{}, error) {
+				return openCache(cfg.(*internal.CacheConfig))
+			},
+			store: func(cfg interface{}) (*sync.Map, string) { return &a.caches, cfg.(*internal.CacheConfig).Alias },
+		})
+	}
+
+	if enabled.Redis {
+		watch(&reloadable{
+			key: glibConfigRedis,
+			parse: func(buf []byte) (map[string]interface{}, error) {
+				return parseReloadItems(cc, glibConfigRedis, buf, &[]*redisConfig{}, func(cfg interface{}) string {
+					return cfg.(*redisConfig).Alias
+				})
+			},
+			open: func(cfg interface{}) (interface{}, error) {
+				return openRedis(cfg.(*redisConfig))
+			},
+			store: func(cfg interface{}) (*sync.Map, string) { return &a.rediss, cfg.(*redisConfig).Alias },
+		})
+	}
+
+	if enabled.Broker {
+		watch(&reloadable{
+			key: glibConfigBroker,
+			parse: func(buf []byte) (map[string]interface{}, error) {
+				return parseReloadItems(cc, glibConfigBroker, buf, &[]*brokerConfig{}, func(cfg interface{}) string {
+					// publisher and consumer may have the same alias
+					return cfg.(*brokerConfig).Type + "/" + cfg.(*brokerConfig).Alias
+				})
+			},
+			open: func(cfg interface{}) (interface{}, error) {
+				return openBroker(cfg.(*brokerConfig))
+			},
+			store: func(cfg interface{}) (*sync.Map, string) {
+				opt := cfg.(*brokerConfig)
+				return a.brokerStore(opt.Type), opt.Alias
+			},
+		})
+	}
+}
+
+// parseReloadItems unmarshal and validate the config list in buf to v(pointer to slice of pointers),
+// returns the enabled items by id.
+func parseReloadItems(cc *configCenter, key string, buf []byte, v interface{}, id func(cfg interface{}) string) (map[string]interface{}, error) {
+	items := make(map[string]interface{})
+	if len(buf) == 0 {
+		return items, nil
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return nil, err
+	}
+	if problems := validateConfig(cc.Options().ServiceDomain+"/"+key, v); len(problems) > 0 {
+		return nil, problems
+	}
+
+	list := reflect.ValueOf(v).Elem()
+	for i := 0; i < list.Len(); i++ {
+		cfg := list.Index(i)
+		if !cfg.IsNil() && configEnabled(cfg.Elem()) {
+			items[id(cfg.Interface())] = cfg.Interface()
+		}
+	}
+	return items, nil
+}
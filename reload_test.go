@@ -0,0 +1,129 @@
+package glib
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/carltd/glib/internal"
+)
+
+type fakeCache struct {
+	internal.Cacher
+	dsn    string
+	closed chan bool
+}
+
+func (c *fakeCache) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestWatchResources_Cache(t *testing.T) {
+	internal.RegisterCacheDriver("fake", func(cfg *internal.CacheConfig) internal.Cacher {
+		return &fakeCache{dsn: cfg.Dsn, closed: make(chan bool)}
+	})
+
+	cc, err := newConfigCenter(WithConfigSource(NewFileSource("testdata/conf")), WithDrainTimeout(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newApp()
+	a.conf = cc
+	a.enabled.Cache = true
+	a.watchResources()
+
+	cc.update(0, map[string][]byte{
+		glibConfigCache: []byte(`[{"enable":true,"alias":"c1","driver":"fake","dsn":"a"},{"enable":true,"alias":"c2","driver":"fake","dsn":"b"}]`),
+	})
+	c1 := a.Cache("c1").(*fakeCache)
+	c2 := a.Cache("c2").(*fakeCache)
+
+	// c1 changed, c2 removed
+	cc.update(0, map[string][]byte{
+		glibConfigCache: []byte(`[{"enable":true,"alias":"c1","driver":"fake","dsn":"x"}]`),
+	})
+	if got := a.Cache("c1").(*fakeCache).dsn; got != "x" {
+		t.Errorf("c1 dsn=%s, want x", got)
+	}
+	if _, ok := a.caches.Load("c2"); ok {
+		t.Error("c2 should be removed")
+	}
+	for _, c := range []*fakeCache{c1, c2} {
+		select {
+		case <-c.closed:
+		case <-time.After(time.Second):
+			t.Errorf("cache %s not closed", c.dsn)
+		}
+	}
+
+	// invalid config is ignored
+	cc.update(0, map[string][]byte{
+		glibConfigCache: []byte(`[{"enable":true,"alias":"c1","driver":"fake"}]`),
+	})
+	if got := a.Cache("c1").(*fakeCache).dsn; got != "x" {
+		t.Errorf("c1 dsn=%s, want x", got)
+	}
+}
+
+func TestReloadable_retry(t *testing.T) {
+	defer func(d time.Duration) { reloadRetryDelay = d }(reloadRetryDelay)
+	reloadRetryDelay = 10 * time.Millisecond
+
+	var (
+		store sync.Map
+		fail  int32 = 1
+	)
+	r := &reloadable{
+		key: "test",
+		parse: func(buf []byte) (map[string]interface{}, error) {
+			var list []string
+			if err := json.Unmarshal(buf, &list); err != nil {
+				return nil, err
+			}
+			items := make(map[string]interface{})
+			for _, id := range list {
+				items[id] = id
+			}
+			return items, nil
+		},
+		open: func(cfg interface{}) (interface{}, error) {
+			if cfg == "b" && atomic.LoadInt32(&fail) == 1 {
+				return nil, errors.New("refused")
+			}
+			return cfg, nil
+		},
+		store: func(cfg interface{}) (*sync.Map, string) { return &store, cfg.(string) },
+	}
+	// opened by Init
+	store.Store("a", "a")
+	r.seed(configObject(`["a"]`))
+
+	retried := make(chan bool, 1)
+	retry := func() {
+		r.reload(configObject(`["a","b"]`), 0, nil)
+		retried <- true
+	}
+	r.reload(configObject(`["a","b"]`), 0, retry)
+	if _, ok := store.Load("b"); ok {
+		t.Fatal("b should fail to open")
+	}
+	atomic.StoreInt32(&fail, 0)
+	select {
+	case <-retried:
+	case <-time.After(time.Second):
+		t.Fatal("b is not retried")
+	}
+	if _, ok := store.Load("b"); !ok {
+		t.Error("b should be opened by the retry")
+	}
+
+	// the items opened are removed, whatever the previous config is
+	r.reload(configObject(`["b"]`), 0, nil)
+	if _, ok := store.Load("a"); ok {
+		t.Error("a should be removed")
+	}
+}
@@ -0,0 +1,261 @@
+package glib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// ErrNoInstance is returned by Balancer.Pick when there is not any healthy instance
+var ErrNoInstance = errors.New("glib: no healthy instance")
+
+// Instance is a healthy instance of a service registered in consul
+type Instance struct {
+	ID      string
+	Service string
+	// host:port
+	Address string
+	Tags    []string
+	Meta    map[string]string
+}
+
+// Resolve returns the healthy instances of service by the default app, see App.Resolve
+func Resolve(service, tag string) ([]*Instance, error) {
+	return defaultApp.Resolve(service, tag)
+}
+
+// WatchService watches the instances of service by the default app, see App.WatchService
+func WatchService(ctx context.Context, service, tag string) (<-chan []*Instance, error) {
+	return defaultApp.WatchService(ctx, service, tag)
+}
+
+// NewServiceBalancer creates a balancer of service by the default app, see App.NewServiceBalancer
+func NewServiceBalancer(ctx context.Context, service, tag string, strategy BalanceStrategy) (*Balancer, error) {
+	return defaultApp.NewServiceBalancer(ctx, service, tag, strategy)
+}
+
+// Resolve returns the instances of service which passed all checks in consul at DiscoverAddr,
+// tag is ignored if it's empty.
+func (a *App) Resolve(service, tag string) ([]*Instance, error) {
+	client, err := a.conf.consulClient()
+	if err != nil {
+		return nil, fmt.Errorf("glib: resolve %s: %v", service, err)
+	}
+	entries, _, err := client.Health().Service(service, tag, true, nil)
+	if err != nil {
+		return nil, fmt.Errorf("glib: resolve %s: %v", service, err)
+	}
+	return toInstances(entries), nil
+}
+
+// WatchService sends the healthy instances of service to the returned channel at first and
+// whenever they changed, the channel is closed when ctx is done or the app is shut down.
+func (a *App) WatchService(ctx context.Context, service, tag string) (<-chan []*Instance, error) {
+	client, err := a.conf.consulClient()
+	if err != nil {
+		return nil, fmt.Errorf("glib: watch service %s: %v", service, err)
+	}
+	entries, meta, err := client.Health().Service(service, tag, true, nil)
+	if err != nil {
+		return nil, fmt.Errorf("glib: watch service %s: %v", service, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan []*Instance, 1)
+	ch <- toInstances(entries)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		// stop when the app is shut down
+		go func() {
+			select {
+			case <-a.ctx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		last, lastIndex := toInstances(entries), meta.LastIndex
+		for {
+			q := &api.QueryOptions{WaitIndex: lastIndex, WaitTime: consulWatchWaitTime}
+			entries, meta, err := client.Health().Service(service, tag, true, q.WithContext(ctx))
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("glib: watch service %s err: %v", service, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(consulWatchRetryDelay):
+				}
+				continue
+			}
+
+			if meta.LastIndex < lastIndex {
+				lastIndex = 0
+			} else {
+				lastIndex = meta.LastIndex
+			}
+			instances := toInstances(entries)
+			if reflect.DeepEqual(last, instances) {
+				continue
+			}
+			last = instances
+			select {
+			case ch <- instances:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// NewServiceBalancer creates a balancer of the healthy instances of service, which are kept
+// up to date until ctx is done or the app is shut down, cancel ctx to release the balancer.
+// it fails if the first query of the instances fails.
+func (a *App) NewServiceBalancer(ctx context.Context, service, tag string, strategy BalanceStrategy) (*Balancer, error) {
+	ch, err := a.WatchService(ctx, service, tag)
+	if err != nil {
+		return nil, err
+	}
+	instances, ok := <-ch
+	if !ok {
+		return nil, fmt.Errorf("glib: watch service %s: %v", service, context.Canceled)
+	}
+	b := NewBalancer(strategy)
+	b.Update(instances)
+	go func() {
+		for instances := range ch {
+			b.Update(instances)
+		}
+	}()
+	return b, nil
+}
+
+func toInstances(entries []*api.ServiceEntry) []*Instance {
+	instances := make([]*Instance, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		instances = append(instances, &Instance{
+			ID:      e.Service.ID,
+			Service: e.Service.Service,
+			Address: net.JoinHostPort(host, strconv.Itoa(e.Service.Port)),
+			Tags:    e.Service.Tags,
+			Meta:    e.Service.Meta,
+		})
+	}
+	return instances
+}
+
+// BalanceStrategy is the way to pick an instance
+type BalanceStrategy int
+
+const (
+	// pick the instances in turn
+	BalanceRoundRobin BalanceStrategy = iota
+	// pick an instance randomly
+	BalanceRandom
+	// pick the instance which failed least recently, the ones never failed are picked in turn
+	BalanceLeastRecentFailure
+)
+
+// Balancer picks an instance by the strategy, it's safe for concurrent use.
+type Balancer struct {
+	strategy BalanceStrategy
+
+	mu        sync.Mutex
+	instances []*Instance
+	next      int
+	rnd       *rand.Rand
+	// last failed time by instance id
+	failedAt map[string]time.Time
+}
+
+// NewBalancer creates a balancer with the strategy, the instances are set by Update
+func NewBalancer(strategy BalanceStrategy) *Balancer {
+	return &Balancer{
+		strategy: strategy,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		failedAt: make(map[string]time.Time),
+	}
+}
+
+// Update replaces the instances
+func (b *Balancer) Update(instances []*Instance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.instances = instances
+
+	// forget the instances gone
+	alive := make(map[string]time.Time, len(b.failedAt))
+	for _, inst := range instances {
+		if t, ok := b.failedAt[inst.ID]; ok {
+			alive[inst.ID] = t
+		}
+	}
+	b.failedAt = alive
+}
+
+// Instances returns the current instances
+func (b *Balancer) Instances() []*Instance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.instances
+}
+
+// Pick returns an instance, ErrNoInstance if there is not any.
+func (b *Balancer) Pick() (*Instance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.instances) == 0 {
+		return nil, ErrNoInstance
+	}
+
+	switch b.strategy {
+	case BalanceRandom:
+		return b.instances[b.rnd.Intn(len(b.instances))], nil
+	case BalanceLeastRecentFailure:
+		var (
+			oldest     time.Time
+			candidates []*Instance
+		)
+		for _, inst := range b.instances {
+			t := b.failedAt[inst.ID]
+			switch {
+			case len(candidates) == 0 || t.Before(oldest):
+				oldest, candidates = t, []*Instance{inst}
+			case t.Equal(oldest):
+				candidates = append(candidates, inst)
+			}
+		}
+		b.next++
+		return candidates[b.next%len(candidates)], nil
+	default:
+		b.next++
+		return b.instances[b.next%len(b.instances)], nil
+	}
+}
+
+// Report tells the result of a request sent to inst, used by BalanceLeastRecentFailure
+func (b *Balancer) Report(inst *Instance, err error) {
+	if err == nil {
+		return
+	}
+	b.mu.Lock()
+	b.failedAt[inst.ID] = time.Now()
+	b.mu.Unlock()
+}
@@ -0,0 +1,163 @@
+package glib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+)
+
+// fakeCatalog serves the healthy entries of a service with blocking queries
+type fakeCatalog struct {
+	mu      sync.Mutex
+	index   uint64
+	entries []*api.ServiceEntry
+	changed chan struct{}
+}
+
+func (f *fakeCatalog) set(entries ...*api.ServiceEntry) {
+	f.mu.Lock()
+	f.index++
+	f.entries = entries
+	close(f.changed)
+	f.changed = make(chan struct{})
+	f.mu.Unlock()
+}
+
+func (f *fakeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/v1/health/service/") {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	index, changed := f.index, f.changed
+	f.mu.Unlock()
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait == index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	_ = json.NewEncoder(w).Encode(f.entries)
+}
+
+func serviceEntry(id, addr string, port int) *api.ServiceEntry {
+	return &api.ServiceEntry{
+		Node:    &api.Node{Address: "10.0.0.1"},
+		Service: &api.AgentService{ID: id, Service: "demo", Address: addr, Port: port},
+	}
+}
+
+func TestApp_WatchService(t *testing.T) {
+	catalog := &fakeCatalog{index: 1, changed: make(chan struct{})}
+	catalog.entries = []*api.ServiceEntry{serviceEntry("a", "", 80)}
+	srv := httptest.NewServer(catalog)
+	defer srv.Close()
+
+	a := newApp()
+	var err error
+	a.conf, err = newConfigCenter(WithConfigSource(NewEnvSource("GLIB_RESOLVE_")), WithDiscoverAddr(strings.TrimPrefix(srv.URL, "http://")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	instances, err := a.Resolve("demo", "")
+	if err != nil || len(instances) != 1 || instances[0].Address != "10.0.0.1:80" {
+		t.Fatalf("resolve %v %v", instances, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := a.WatchService(ctx, "demo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-ch; len(got) != 1 {
+		t.Fatalf("got %v", got)
+	}
+	catalog.set(serviceEntry("a", "", 80), serviceEntry("b", "10.0.0.2", 81))
+	select {
+	case got := <-ch:
+		if len(got) != 2 || got[1].Address != "10.0.0.2:81" {
+			t.Errorf("got %v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("changes not received")
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestApp_NewServiceBalancer(t *testing.T) {
+	catalog := &fakeCatalog{index: 1, changed: make(chan struct{})}
+	catalog.entries = []*api.ServiceEntry{serviceEntry("a", "", 80)}
+	srv := httptest.NewServer(catalog)
+
+	a := newApp()
+	var err error
+	a.conf, err = newConfigCenter(WithConfigSource(NewEnvSource("GLIB_RESOLVE_")), WithDiscoverAddr(strings.TrimPrefix(srv.URL, "http://")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b, err := a.NewServiceBalancer(ctx, "demo", "", BalanceRoundRobin)
+	if err != nil || len(b.Instances()) != 1 {
+		t.Fatalf("balancer %v err %v", b, err)
+	}
+	cancel()
+
+	// the first query fails
+	srv.Close()
+	if _, err = a.NewServiceBalancer(context.Background(), "demo", "", BalanceRoundRobin); err == nil {
+		t.Error("balancer without instances queried should fail")
+	}
+}
+
+func TestBalancer(t *testing.T) {
+	instances := []*Instance{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	b := NewBalancer(BalanceRoundRobin)
+	if _, err := b.Pick(); err != ErrNoInstance {
+		t.Errorf("empty balancer err=%v", err)
+	}
+	b.Update(instances)
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		inst, _ := b.Pick()
+		seen[inst.ID]++
+	}
+	if seen["a"] != 2 || seen["b"] != 2 || seen["c"] != 2 {
+		t.Errorf("round robin picked %v", seen)
+	}
+
+	b = NewBalancer(BalanceLeastRecentFailure)
+	b.Update(instances)
+	b.Report(instances[0], errors.New("timeout"))
+	time.Sleep(time.Millisecond)
+	b.Report(instances[1], errors.New("timeout"))
+	for i := 0; i < 3; i++ {
+		if inst, _ := b.Pick(); inst.ID != "c" {
+			t.Errorf("picked %s, want the one never failed", inst.ID)
+		}
+	}
+	b.Update(instances[:2])
+	if inst, _ := b.Pick(); inst.ID != "a" {
+		t.Errorf("picked %s, want the least recent failed", inst.ID)
+	}
+}
@@ -0,0 +1,60 @@
+package glib
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// kinds of the resources managed by glib
+const (
+	KindDB        = "db"
+	KindCache     = "cache"
+	KindRedis     = "redis"
+	KindMgo       = "mgo"
+	KindPublisher = "publisher"
+	KindConsumer  = "consumer"
+	KindTracer    = "tracer"
+	// the service registered in consul, see WithRegister
+	KindService = "service"
+	// the distributed locks held, see Lock
+	KindLock = "lock"
+)
+
+// ErrAliasNotConfigured is returned by the Lookup* functions
+// when the alias of the resource kind is not configured.
+type ErrAliasNotConfigured struct {
+	Kind  string
+	Alias string
+}
+
+func (e *ErrAliasNotConfigured) Error() string {
+	return fmt.Sprintf("glib: %s[%s] not configed", e.Kind, e.Alias)
+}
+
+// Aliases returns the sorted aliases of every resource kind
+func Aliases() map[string][]string {
+	return defaultApp.Aliases()
+}
+
+// Aliases - see the package function Aliases
+func (a *App) Aliases() map[string][]string {
+	return map[string][]string{
+		KindDB:        syncMapKeys(&a.dbs),
+		KindCache:     syncMapKeys(&a.caches),
+		KindRedis:     syncMapKeys(&a.rediss),
+		KindMgo:       syncMapKeys(&a.mgos),
+		KindPublisher: syncMapKeys(&a.publishers),
+		KindConsumer:  syncMapKeys(&a.consumers),
+	}
+}
+
+func syncMapKeys(m *sync.Map) []string {
+	keys := make([]string, 0)
+	m.Range(func(key, value interface{}) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,33 @@
+package glib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	a := newApp()
+	a.caches.Store("c1", &fakeCache{})
+
+	if _, err := a.LookupCache("c1"); err != nil {
+		t.Error(err)
+	}
+
+	_, err := a.LookupCache("none")
+	if e, ok := err.(*ErrAliasNotConfigured); !ok || e.Kind != KindCache || e.Alias != "none" {
+		t.Errorf("err=%#v", err)
+	}
+
+	func() {
+		defer func() {
+			if _, ok := recover().(*ErrAliasNotConfigured); !ok {
+				t.Error("Cache should panic with *ErrAliasNotConfigured")
+			}
+		}()
+		a.Cache("none")
+	}()
+
+	if got := a.Aliases()[KindCache]; !reflect.DeepEqual(got, []string{"c1"}) {
+		t.Errorf("cache aliases=%v", got)
+	}
+}
@@ -0,0 +1,210 @@
+package glib
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ShardFunc returns the index of the shard of key, which should be in [0, shards)
+type ShardFunc func(key interface{}, shards int) int
+
+// ShardByModulo routes the integer keys(eg: tenant id) by key mod shards, other keys are routed by ShardByHash
+func ShardByModulo(key interface{}, shards int) int {
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := int64(shards)
+		return int((v.Int()%s + s) % s)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int(v.Uint() % uint64(shards))
+	}
+	return ShardByHash(key, shards)
+}
+
+// ShardByHash routes key by the fnv-1a hash of its text
+func ShardByHash(key interface{}, shards int) int {
+	h := fnv.New32a()
+	_, _ = fmt.Fprint(h, key)
+	return int(h.Sum32() % uint32(shards))
+}
+
+type shard struct {
+	aliases []string
+	// aliases without the duplicated ones, in the order of aliases
+	distinct []string
+	fn       ShardFunc
+}
+
+// the shards by logical name, they are shared by all apps
+var shards sync.Map
+
+// RegisterShard makes the logical db name route to the db aliases by fn, the order of aliases
+// matters, the index returned by fn is the one of aliases. an alias may be listed more than once,
+// eg: 4 logical shards on 2 dbs, ShardEach runs once on it. it panics if the name is registered twice.
+func RegisterShard(name string, fn ShardFunc, aliases ...string) {
+	if fn == nil || len(aliases) == 0 {
+		panic("glib: RegisterShard " + name + " without func or aliases")
+	}
+	s := &shard{aliases: aliases, fn: fn}
+	seen := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		if !seen[alias] {
+			seen[alias] = true
+			s.distinct = append(s.distinct, alias)
+		}
+	}
+	if _, dup := shards.LoadOrStore(name, s); dup {
+		panic("glib: RegisterShard called twice for " + name)
+	}
+}
+
+func lookupShard(name string) (*shard, error) {
+	s, ok := shards.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("glib: shard %s not registered", name)
+	}
+	return s.(*shard), nil
+}
+
+// ShardAlias returns the db alias of key in the logical db name, eg: to run WithTx on the shard
+func ShardAlias(name string, key interface{}) (string, error) {
+	s, err := lookupShard(name)
+	if err != nil {
+		return "", err
+	}
+	i := s.fn(key, len(s.aliases))
+	if i < 0 || i >= len(s.aliases) {
+		return "", fmt.Errorf("glib: shard %s routes %v to %d, out of %d aliases", name, key, i, len(s.aliases))
+	}
+	return s.aliases[i], nil
+}
+
+// ShardDB returns the master of the shard of key by the default app, see App.ShardDB
+func ShardDB(ctx context.Context, name string, key interface{}) *gorm.DB {
+	return defaultApp.ShardDB(ctx, name, key)
+}
+
+// LookupShardDB returns the master of the shard of key by the default app, see App.LookupShardDB
+func LookupShardDB(ctx context.Context, name string, key interface{}) (*gorm.DB, error) {
+	return defaultApp.LookupShardDB(ctx, name, key)
+}
+
+// ShardEach runs fn on every shard of name by the default app, see App.ShardEach
+func ShardEach(ctx context.Context, name string, fn func(alias string, db *gorm.DB) error) error {
+	return defaultApp.ShardEach(ctx, name, fn)
+}
+
+// ShardFind runs query on every shard of name by the default app, see App.ShardFind
+func ShardFind(ctx context.Context, name string, dest interface{}, query func(db *gorm.DB) *gorm.DB) error {
+	return defaultApp.ShardFind(ctx, name, dest, query)
+}
+
+// ShardDB returns the master of the shard of key in the logical db name,
+// its operations are traced as the children of the span in ctx, panic if it's not exists
+func (a *App) ShardDB(ctx context.Context, name string, key interface{}) *gorm.DB {
+	db, err := a.LookupShardDB(ctx, name, key)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// LookupShardDB - see ShardDB, it returns an error instead of panic
+func (a *App) LookupShardDB(ctx context.Context, name string, key interface{}) (*gorm.DB, error) {
+	alias, err := ShardAlias(name, key)
+	if err != nil {
+		return nil, err
+	}
+	db, err := a.LookupDB(alias)
+	if err != nil {
+		return nil, err
+	}
+	return db.Set(dbContextKey, ctx), nil
+}
+
+// ShardEach runs fn on a replica(see DBRead) of every distinct alias of name concurrently,
+// returns the error of the first failed shard in the order of aliases.
+func (a *App) ShardEach(ctx context.Context, name string, fn func(alias string, db *gorm.DB) error) error {
+	s, err := lookupShard(name)
+	if err != nil {
+		return err
+	}
+	dbs := make([]*gorm.DB, len(s.distinct))
+	for i, alias := range s.distinct {
+		db, err := a.LookupDBRead(alias)
+		if err != nil {
+			return err
+		}
+		dbs[i] = db.Set(dbContextKey, ctx)
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, len(dbs))
+	)
+	for i := range dbs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fn(s.distinct[i], dbs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("glib: shard %s (%s) %v", name, s.distinct[i], err)
+		}
+	}
+	return nil
+}
+
+// ShardFind runs query(db).Find on every shard of name and appends the rows into dest,
+// which is a pointer to slice, the rows are in the order of aliases, then the order of each query,
+// so ordering and limit across the shards should be done by the caller.
+//
+//	var orders []*Order
+//	err := glib.ShardFind(ctx, "orders", &orders, func(db *gorm.DB) *gorm.DB {
+//		return db.Where("created_at > ?", since)
+//	})
+func (a *App) ShardFind(ctx context.Context, name string, dest interface{}, query func(db *gorm.DB) *gorm.DB) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("glib: ShardFind dest should be a pointer to slice, got %T", dest)
+	}
+	s, err := lookupShard(name)
+	if err != nil {
+		return err
+	}
+
+	parts := make(map[string]reflect.Value, len(s.distinct))
+	var mu sync.Mutex
+	err = a.ShardEach(ctx, name, func(alias string, db *gorm.DB) error {
+		part := reflect.New(v.Elem().Type())
+		if err := query(db).Find(part.Interface()).Error; err != nil {
+			return err
+		}
+		mu.Lock()
+		parts[alias] = part.Elem()
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	rows := v.Elem()
+	for _, alias := range s.distinct {
+		rows = reflect.AppendSlice(rows, parts[alias])
+	}
+	v.Elem().Set(rows)
+	return nil
+}
@@ -0,0 +1,84 @@
+package glib
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+func TestShardFunc(t *testing.T) {
+	if ShardByModulo(7, 4) != 3 || ShardByModulo(int64(-7), 4) != 1 || ShardByModulo(uint8(8), 4) != 0 {
+		t.Error("ShardByModulo")
+	}
+	if i := ShardByModulo(int64(math.MinInt64), 3); i < 0 || i >= 3 {
+		t.Error("ShardByModulo")
+	}
+	for _, key := range []interface{}{"tenant-a", "tenant-b", 3.5} {
+		if i := ShardByModulo(key, 3); i != ShardByHash(key, 3) || i < 0 || i >= 3 {
+			t.Errorf("%v routed to %d", key, i)
+		}
+	}
+}
+
+func TestApp_ShardDB(t *testing.T) {
+	a := newApp()
+	defer a.Close()
+	err := a.runDBManger(
+		&dbConfig{Enable: true, Alias: "shard0", Driver: "sqlite3", Dsn: "file:shard0?mode=memory", MaxIdle: 1, MaxOpen: 1},
+		&dbConfig{Enable: true, Alias: "shard1", Driver: "sqlite3", Dsn: "file:shard1?mode=memory", MaxIdle: 1, MaxOpen: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterShard("items", ShardByModulo, "shard0", "shard1")
+	defer shards.Delete("items")
+
+	ctx := context.Background()
+	for id := 1; id <= 5; id++ {
+		db := a.ShardDB(ctx, "items", id)
+		if err = db.AutoMigrate(&txItem{}).Create(&txItem{ID: id}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	var n int
+	a.DB("shard1").Model(&txItem{}).Count(&n)
+	if n != 3 {
+		t.Errorf("shard1 has %d items, want 3", n)
+	}
+
+	var items []txItem
+	err = a.ShardFind(ctx, "items", &items, func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{2, 4, 1, 3, 5}
+	if len(items) != len(want) {
+		t.Fatalf("items=%v", items)
+	}
+	for i, it := range items {
+		if it.ID != want[i] {
+			t.Errorf("items=%v, want ids %v", items, want)
+			break
+		}
+	}
+
+	// the logical shards on the same db are queried once
+	items = nil
+	RegisterShard("items4", ShardByModulo, "shard0", "shard1", "shard0", "shard1")
+	defer shards.Delete("items4")
+	if err = a.ShardFind(ctx, "items4", &items, func(db *gorm.DB) *gorm.DB { return db }); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != len(want) {
+		t.Errorf("items=%v, want %d", items, len(want))
+	}
+
+	if _, err = a.LookupShardDB(ctx, "none", 1); err == nil {
+		t.Error("unregistered shard should fail")
+	}
+	if err = a.ShardFind(ctx, "items", items, func(db *gorm.DB) *gorm.DB { return db }); err == nil {
+		t.Error("dest not a pointer should fail")
+	}
+}
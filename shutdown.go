@@ -0,0 +1,163 @@
+package glib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	gtrace "github.com/carltd/glib/trace"
+)
+
+// ResourceError is an error of the resource in kind with alias
+type ResourceError struct {
+	Kind  string
+	Alias string
+	Err   error
+}
+
+func (e *ResourceError) Error() string {
+	return fmt.Sprintf("%s[%s]: %v", e.Kind, e.Alias, e.Err)
+}
+
+// ShutdownError lists every resource failed to close
+type ShutdownError []*ResourceError
+
+func (e ShutdownError) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("glib: %d resource(s) failed to close:", len(e)))
+	for _, re := range e {
+		lines = append(lines, "\t"+re.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// a resource to close
+type closing struct {
+	kind, alias string
+	close       func() error
+}
+
+// Shutdown releases the resources managed by the default app, see App.Shutdown
+func Shutdown(ctx context.Context) error {
+	return defaultApp.Shutdown(ctx)
+}
+
+// Shutdown releases the resources in order: the service is deregistered from consul
+// and the distributed locks are released first,
+// consumers are drained, then publishers and the trace reporter are flushed,
+// at last the stores(cache, redis, mgo, db) are closed.
+// resources not closed before ctx is done are reported with ctx's error, and keep closing
+// in background. every failed resource is listed in the returned ShutdownError.
+func (a *App) Shutdown(ctx context.Context) error {
+	var errs ShutdownError
+	a.shutdownOnce.Do(func() {
+		// stop watching config and health checks
+		a.stop()
+
+		stages := [][]*closing{
+			nil,
+			a.closings(KindConsumer, &a.consumers),
+			a.closings(KindPublisher, &a.publishers),
+			nil,
+			append(append(append(
+				a.closings(KindCache, &a.caches),
+				a.closings(KindRedis, &a.rediss)...),
+				a.closings(KindMgo, &a.mgos)...),
+				a.closings(KindDB, &a.dbs)...),
+		}
+		stages[0] = a.lockClosings()
+		if a.registered != nil {
+			stages[0] = append(stages[0], a.registered)
+		}
+		// the reporter is shared by the process, only the app initialized it last closes it
+		if a.releaseTracer() {
+			stages[3] = []*closing{{kind: KindTracer, close: gtrace.Close}}
+		}
+
+		for _, stage := range stages {
+			errs = append(errs, closeAll(ctx, stage)...)
+		}
+	})
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (a *App) closings(kind string, m *sync.Map) []*closing {
+	ret := make([]*closing, 0)
+	m.Range(func(key, value interface{}) bool {
+		c := &closing{kind: kind, alias: key.(string)}
+		switch v := value.(type) {
+		case io.Closer:
+			c.close = v.Close
+		case interface{ Close() }:
+			c.close = func() error { v.Close(); return nil }
+		default:
+			// nothing to close, eg: some cachers
+			return true
+		}
+		ret = append(ret, c)
+		return true
+	})
+	return ret
+}
+
+var (
+	tracerMu sync.Mutex
+	// the app initialized the tracer last
+	tracerOwner *App
+)
+
+// ownTracer marks a as the owner of the process-wide tracer
+func (a *App) ownTracer() {
+	tracerMu.Lock()
+	tracerOwner = a
+	tracerMu.Unlock()
+}
+
+// releaseTracer reports whether a owns the tracer, and gives it up
+func (a *App) releaseTracer() bool {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	if tracerOwner != a {
+		return false
+	}
+	tracerOwner = nil
+	return true
+}
+
+// closeAll closes the resources concurrently, returns when all closed or ctx is done
+func closeAll(ctx context.Context, cs []*closing) []*ResourceError {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]*ResourceError, 0)
+	)
+
+	for _, c := range cs {
+		wg.Add(1)
+		go func(c *closing) {
+			defer wg.Done()
+			done := make(chan error, 1)
+			go func() { done <- c.close() }()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, &ResourceError{Kind: c.kind, Alias: c.alias, Err: err})
+				mu.Unlock()
+			}
+		}(c)
+	}
+	wg.Wait()
+	return errs
+}
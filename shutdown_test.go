@@ -0,0 +1,96 @@
+package glib
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type orderCloser struct {
+	name  string
+	delay time.Duration
+	err   error
+	mu    *sync.Mutex
+	order *[]string
+}
+
+func (c *orderCloser) Close() error {
+	time.Sleep(c.delay)
+	c.mu.Lock()
+	*c.order = append(*c.order, c.name)
+	c.mu.Unlock()
+	return c.err
+}
+
+func TestApp_Shutdown(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+		a     = newApp()
+	)
+	closer := func(name string, delay time.Duration, err error) *orderCloser {
+		return &orderCloser{name: name, delay: delay, err: err, mu: &mu, order: &order}
+	}
+	a.dbs.Store("db1", closer("db1", 0, nil))
+	a.rediss.Store("r1", closer("r1", 0, errors.New("closed")))
+	a.publishers.Store("p1", closer("p1", 0, nil))
+	a.consumers.Store("c1", closer("c1", 10*time.Millisecond, nil))
+
+	err := a.Shutdown(context.Background())
+	if want := []string{"c1", "p1"}; !reflect.DeepEqual(order[:2], want) {
+		t.Errorf("close order=%v, want %v first", order, want)
+	}
+	if errs, ok := err.(ShutdownError); !ok || len(errs) != 1 || errs[0].Kind != KindRedis || errs[0].Alias != "r1" {
+		t.Errorf("err=%v", err)
+	}
+
+	// closed only once
+	if err = a.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApp_ShutdownDeadline(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+		a     = newApp()
+	)
+	a.consumers.Store("slow", &orderCloser{name: "slow", delay: time.Second, mu: &mu, order: &order})
+	a.dbs.Store("db1", &orderCloser{name: "db1", mu: &mu, order: &order})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := a.Shutdown(ctx)
+	if cost := time.Since(start); cost > 500*time.Millisecond {
+		t.Errorf("shutdown cost %v, the deadline is not honoured", cost)
+	}
+	// resources not closed before the deadline are reported
+	errs, ok := err.(ShutdownError)
+	if !ok || len(errs) == 0 || errs[0].Alias != "slow" || errs[0].Err != context.DeadlineExceeded {
+		t.Errorf("err=%v", err)
+	}
+}
+
+func TestApp_ShutdownTracerOwner(t *testing.T) {
+	owner, other := newApp(), newApp()
+	owner.ownTracer()
+	defer func() { tracerOwner = nil }()
+
+	if err := other.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tracerOwner != owner {
+		t.Error("the tracer of another app is released")
+	}
+	if err := owner.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tracerOwner != nil {
+		t.Error("the owner should release the tracer")
+	}
+}
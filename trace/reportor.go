@@ -1,18 +1,57 @@
 package gtrace
 
 import (
+	"sync"
+
 	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/reporter"
 	"github.com/openzipkin/zipkin-go/reporter/http"
 )
 
 const defaultTracerAddr = "http://host.docker.internal:9411/api/v2/spans"
 
 var (
-	tc *zipkin.Tracer
+	mu sync.RWMutex
+	// nil if it's not initialized or closed
+	tc     *zipkin.Tracer
+	report *closableReporter
 )
 
+// tracer returns the tracer, nil if it's not initialized or closed
+func tracer() *zipkin.Tracer {
+	mu.RLock()
+	defer mu.RUnlock()
+	return tc
+}
+
+// closableReporter drops the spans finished after Close, the http reporter blocks on them forever
+type closableReporter struct {
+	mu     sync.RWMutex
+	closed bool
+	r      reporter.Reporter
+}
+
+func (c *closableReporter) Send(s model.SpanModel) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.closed {
+		c.r.Send(s)
+	}
+}
+
+func (c *closableReporter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.r.Close()
+}
+
 type TracerConfig struct {
-	Address   string `json:"addr"`
+	Address   string `json:"addr" validate:"url"`
 	SampleMod uint64 `json:"sample_mod"`
 
 	HostPort string `json:"-"`
@@ -20,22 +59,52 @@ type TracerConfig struct {
 }
 
 func InitTracer(opt TracerConfig) error {
-	ep, err := zipkin.NewEndpoint(opt.SrvName, opt.HostPort)
-	if err != nil {
-		return err
-	}
-
 	if len(opt.Address) == 0 {
 		opt.Address = defaultTracerAddr
 	}
+	return InitTracerWithReporter(opt, http.NewReporter(opt.Address))
+}
 
-	var report = http.NewReporter(opt.Address)
+// InitTracerWithReporter is like InitTracer, but the spans are sent to r instead of opt.Address,
+// eg: a recorder in tests. the previous tracer is closed.
+func InitTracerWithReporter(opt TracerConfig, r reporter.Reporter) error {
+	ep, err := zipkin.NewEndpoint(opt.SrvName, opt.HostPort)
+	if err != nil {
+		_ = r.Close()
+		return err
+	}
 
 	// initialize the tracer
-	tc, err = zipkin.NewTracer(
-		report,
+	cr := &closableReporter{r: r}
+	t, err := zipkin.NewTracer(
+		cr,
 		zipkin.WithLocalEndpoint(ep),
 		zipkin.WithSampler(zipkin.NewModuloSampler(opt.SampleMod)), // always sampler
 	)
-	return err
+	if err != nil {
+		_ = cr.Close()
+		return err
+	}
+
+	mu.Lock()
+	prev := report
+	tc, report = t, cr
+	mu.Unlock()
+	if prev != nil {
+		_ = prev.Close()
+	}
+	return nil
+}
+
+// Close flushes the spans and closes the reporter, the spans started or finished after it are dropped.
+// it's safe to call it more than once.
+func Close() error {
+	mu.Lock()
+	r := report
+	tc, report = nil, nil
+	mu.Unlock()
+	if r == nil {
+		return nil
+	}
+	return r.Close()
 }
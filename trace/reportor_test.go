@@ -0,0 +1,57 @@
+package gtrace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openzipkin/zipkin-go"
+	"github.com/openzipkin/zipkin-go/reporter/recorder"
+)
+
+type countingReporter struct {
+	*recorder.ReporterRecorder
+	closed int
+}
+
+func (r *countingReporter) Close() error {
+	r.closed++
+	return r.ReporterRecorder.Close()
+}
+
+func TestClose(t *testing.T) {
+	first := &countingReporter{ReporterRecorder: recorder.NewReporter()}
+	if err := InitTracerWithReporter(TracerConfig{SrvName: "test"}, first); err != nil {
+		t.Fatal(err)
+	}
+	// the previous one is closed by init
+	if err := InitTracer(TracerConfig{SrvName: "test", Address: "http://127.0.0.1:1/api/v2/spans"}); err != nil {
+		t.Fatal(err)
+	}
+	if first.closed != 1 {
+		t.Errorf("previous reporter closed %d times, want 1", first.closed)
+	}
+
+	sp := tracer().StartSpan("parent")
+	if err := Close(); err != nil {
+		t.Log(err)
+	}
+	if err := Close(); err != nil {
+		t.Errorf("close twice err: %v", err)
+	}
+
+	// the span finished after close is dropped instead of blocking
+	done := make(chan bool)
+	go func() {
+		sp.Finish()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("span finished after close blocks")
+	}
+	if child, _ := StartSpanFromContext(zipkin.NewContext(context.Background(), sp), "child"); child != nil {
+		t.Error("no span should be started after close")
+	}
+}
@@ -0,0 +1,23 @@
+package gtrace
+
+import (
+	"context"
+
+	"github.com/openzipkin/zipkin-go"
+)
+
+// StartSpanFromContext starts a child span of the span in ctx or the go-micro metadata,
+// it returns nil span if there is no parent span or the tracer is not initialized.
+func StartSpanFromContext(ctx context.Context, name string) (zipkin.Span, context.Context) {
+	tc := tracer()
+	if tc == nil || ctx == nil {
+		return nil, ctx
+	}
+	if zipkin.SpanFromContext(ctx) != nil {
+		return tc.StartSpanFromContext(ctx, name)
+	}
+	if parent := getTraceFromCtx(ctx); parent != nil {
+		return tc.StartSpanFromContext(ctx, name, zipkin.Parent(*parent))
+	}
+	return nil, ctx
+}
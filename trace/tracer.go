@@ -41,6 +41,10 @@ type clientWrapper struct{ client.Client }
 
 // Call implements client.Client.Call.
 func (w *clientWrapper) Call(ctx context.Context, req client.Request, rsp interface{}, opts ...client.CallOption) (err error) {
+	tc := tracer()
+	if tc == nil {
+		return w.Client.Call(ctx, req, rsp, opts...)
+	}
 	var sp, cCtx = tc.StartSpanFromContext(ctx, "rpc/client/"+req.Service()+"/"+req.Method())
 
 	defer func() {
@@ -56,6 +60,10 @@ func (w *clientWrapper) Call(ctx context.Context, req client.Request, rsp interf
 
 // Publish implements client.Client.Publish.
 func (w *clientWrapper) Publish(ctx context.Context, p client.Message, opts ...client.PublishOption) (err error) {
+	tc := tracer()
+	if tc == nil {
+		return w.Client.Publish(ctx, p, opts...)
+	}
 	var sp, cCtx = tc.StartSpanFromContext(ctx, "rpc/pub/"+p.Topic())
 
 	defer func() {
@@ -104,6 +112,10 @@ func injectTraceIntoCtx(ctx context.Context, sp zipkin.Span) context.Context {
 func NewHandlerWrapper() server.HandlerWrapper {
 	return func(fn server.HandlerFunc) server.HandlerFunc {
 		return func(ctx context.Context, req server.Request, rsp interface{}) (err error) {
+			tc := tracer()
+			if tc == nil {
+				return fn(ctx, req, rsp)
+			}
 
 			var sp zipkin.Span
 			var spanCtx = getTraceFromCtx(ctx)
@@ -131,6 +143,11 @@ func NewHandlerWrapper() server.HandlerWrapper {
 func NewSubscriberWrapper() server.SubscriberWrapper {
 	return func(fn server.SubscriberFunc) server.SubscriberFunc {
 		return func(ctx context.Context, p server.Message) (err error) {
+			tc := tracer()
+			if tc == nil {
+				return fn(ctx, p)
+			}
+
 			var sp zipkin.Span
 			var spanCtx = getTraceFromCtx(ctx)
 			if spanCtx != nil {
@@ -0,0 +1,154 @@
+package glib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	defaultTxRetries = 3
+	// key of the savepoint depth in *gorm.DB
+	txSavepointKey = "glib:savepoint"
+)
+
+type txOptions struct {
+	// times to retry after the first attempt
+	Retries int
+	// time to wait before the attempt, which starts from 1
+	Backoff func(attempt int) time.Duration
+}
+
+type txOption func(*txOptions)
+
+// WithTxRetries - times to retry the transaction failed by deadlock or lock wait timeout, default is 3
+func WithTxRetries(n int) txOption {
+	return func(o *txOptions) {
+		o.Retries = n
+	}
+}
+
+// WithTxBackoff - time to wait before retrying, default is 10ms, 20ms, 40ms ...
+func WithTxBackoff(fn func(attempt int) time.Duration) txOption {
+	return func(o *txOptions) {
+		o.Backoff = fn
+	}
+}
+
+func defaultTxBackoff(attempt int) time.Duration {
+	return (10 * time.Millisecond) << uint(attempt-1)
+}
+
+// WithTx runs fn in a transaction of the db alias by the default app, see App.WithTx
+func WithTx(ctx context.Context, alias string, fn func(tx *gorm.DB) error, opts ...txOption) error {
+	return defaultApp.WithTx(ctx, alias, fn, opts...)
+}
+
+// WithTx runs fn in a transaction of the master of alias, it's committed if fn returns nil,
+// or rolled back if fn returns an error or panics(the panic goes on).
+// the whole transaction is retried if it failed by mysql deadlock(1213) or lock wait timeout(1205),
+// so fn should not have side effects out of the transaction.
+// ctx is checked before every attempt and the commit, the operations of tx are traced as the children of its span.
+func (a *App) WithTx(ctx context.Context, alias string, fn func(tx *gorm.DB) error, opts ...txOption) error {
+	db, err := a.LookupDB(alias)
+	if err != nil {
+		return err
+	}
+
+	o := txOptions{Retries: defaultTxRetries, Backoff: defaultTxBackoff}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(o.Backoff(attempt)):
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		err = runTx(ctx, db, fn)
+		if err == nil || attempt >= o.Retries || !isRetryableTxError(err) {
+			return err
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	tx := db.Set(dbContextKey, ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// WithSavepoint runs fn in a savepoint of tx, which is started by WithTx, it's released if fn
+// returns nil, or rolled back to if fn returns an error or panics, the outer transaction goes on.
+// it can be nested.
+func WithSavepoint(tx *gorm.DB, fn func(tx *gorm.DB) error) (err error) {
+	depth, _ := tx.Get(txSavepointKey)
+	n, _ := depth.(int)
+	name := fmt.Sprintf("glib_sp_%d", n+1)
+
+	if err = tx.Exec("SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Exec("ROLLBACK TO SAVEPOINT " + name)
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx.Set(txSavepointKey, n+1)); err != nil {
+		if e := tx.Exec("ROLLBACK TO SAVEPOINT " + name).Error; e != nil {
+			return fmt.Errorf("glib: rollback to savepoint err: %v, after %v", e, err)
+		}
+		return err
+	}
+	return tx.Exec("RELEASE SAVEPOINT " + name).Error
+}
+
+// isRetryableTxError reports whether err is or wraps mysql deadlock(1213) or lock wait timeout(1205)
+func isRetryableTxError(err error) bool {
+	var errs gorm.Errors
+	if errors.As(err, &errs) {
+		for _, err := range errs {
+			if isRetryableTxError(err) {
+				return true
+			}
+		}
+		return false
+	}
+	var e *mysql.MySQLError
+	if errors.As(err, &e) {
+		return e.Number == 1213 || e.Number == 1205
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package glib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+)
+
+type txItem struct {
+	ID   int
+	Name string
+}
+
+func newTxTestApp(t *testing.T) *App {
+	a := newApp()
+	err := a.runDBManger(&dbConfig{Enable: true, Alias: "db1", Driver: "sqlite3", Dsn: "file:tx?mode=memory", MaxIdle: 1, MaxOpen: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.DB("db1").AutoMigrate(&txItem{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func countTxItems(a *App) (n int) {
+	a.DB("db1").Model(&txItem{}).Count(&n)
+	return
+}
+
+func TestApp_WithTx(t *testing.T) {
+	a := newTxTestApp(t)
+	defer a.Close()
+	ctx := context.Background()
+
+	// committed
+	err := a.WithTx(ctx, "db1", func(tx *gorm.DB) error {
+		return tx.Create(&txItem{Name: "a"}).Error
+	})
+	if err != nil || countTxItems(a) != 1 {
+		t.Fatalf("err=%v count=%d", err, countTxItems(a))
+	}
+
+	// rolled back by error
+	bad := errors.New("bad")
+	err = a.WithTx(ctx, "db1", func(tx *gorm.DB) error {
+		tx.Create(&txItem{Name: "b"})
+		return bad
+	})
+	if err != bad || countTxItems(a) != 1 {
+		t.Errorf("err=%v count=%d", err, countTxItems(a))
+	}
+
+	// rolled back by panic
+	func() {
+		defer func() { recover() }()
+		_ = a.WithTx(ctx, "db1", func(tx *gorm.DB) error {
+			tx.Create(&txItem{Name: "c"})
+			panic("oops")
+		})
+	}()
+	if countTxItems(a) != 1 {
+		t.Errorf("count=%d after panic", countTxItems(a))
+	}
+
+	// savepoint rolled back, the outer one committed
+	err = a.WithTx(ctx, "db1", func(tx *gorm.DB) error {
+		tx.Create(&txItem{Name: "d"})
+		_ = WithSavepoint(tx, func(tx *gorm.DB) error {
+			tx.Create(&txItem{Name: "e"})
+			return WithSavepoint(tx, func(tx *gorm.DB) error {
+				tx.Create(&txItem{Name: "f"})
+				return bad
+			})
+		})
+		return nil
+	})
+	if err != nil || countTxItems(a) != 2 {
+		t.Errorf("err=%v count=%d", err, countTxItems(a))
+	}
+}
+
+func TestApp_WithTxRetry(t *testing.T) {
+	a := newTxTestApp(t)
+	defer a.Close()
+
+	var (
+		attempts int
+		waits    []int
+		deadlock = &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+		backoff  = WithTxBackoff(func(attempt int) time.Duration {
+			waits = append(waits, attempt)
+			return time.Millisecond
+		})
+	)
+	err := a.WithTx(context.Background(), "db1", func(tx *gorm.DB) error {
+		if attempts++; attempts < 3 {
+			return deadlock
+		}
+		return nil
+	}, backoff)
+	if err != nil || attempts != 3 || len(waits) != 2 {
+		t.Errorf("err=%v attempts=%d waits=%v", err, attempts, waits)
+	}
+
+	attempts = 0
+	err = a.WithTx(context.Background(), "db1", func(tx *gorm.DB) error {
+		attempts++
+		return deadlock
+	}, backoff, WithTxRetries(1))
+	if err != deadlock || attempts != 2 {
+		t.Errorf("err=%v attempts=%d", err, attempts)
+	}
+	for _, err := range []error{
+		deadlock,
+		&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"},
+		fmt.Errorf("update stock: %w", deadlock),
+		gorm.Errors{errors.New("other"), fmt.Errorf("update stock: %w", deadlock)},
+		fmt.Errorf("commit: %w", gorm.Errors{deadlock}),
+	} {
+		if !isRetryableTxError(err) {
+			t.Errorf("%v should be retried", err)
+		}
+	}
+	if isRetryableTxError(&mysql.MySQLError{Number: 1062}) || isRetryableTxError(errors.New("deadlock")) {
+		t.Error("other errors should not be retried")
+	}
+}